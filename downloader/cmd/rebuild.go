@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/http_client"
+	"github.com/gcottom/yt-dl-services/downloader/services/download"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+)
+
+// RunRebuild rebuilds the track table from the provenance tags of the files
+// in the download directory and prints the report.
+func RunRebuild(cfg *config.Config) error {
+	ctx := zaplog.CreateAndInject(context.Background())
+	zaplog.InfoC(ctx, "rebuilding track table from the library")
+
+	trackSQL, err := track_sql.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	downloadService := download.NewDownloadService(cfg, http_client.NewHTTPClient(), trackSQL)
+	report, err := downloadService.RebuildLibrary(ctx)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
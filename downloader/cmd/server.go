@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/gcottom/go-zaplog"
 	"github.com/gcottom/qgin/qgin"
@@ -19,6 +20,12 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-db" {
+		if err := RunRebuild(config); err != nil {
+			panic(err)
+		}
+		return
+	}
 	if err := RunServer(config); err != nil {
 		panic(err)
 	}
@@ -46,6 +53,12 @@ func RunServer(cfg *config.Config) error {
 	zaplog.InfoC(ctx, "starting re-driver")
 	go downloadService.ReDriverProcessor(ctx)
 
+	zaplog.InfoC(ctx, "starting retag processor")
+	go downloadService.RetagProcessor(ctx)
+
+	zaplog.InfoC(ctx, "starting reconcile processor")
+	go downloadService.ReconcileProcessor(ctx)
+
 	zaplog.InfoC(ctx, "creating gin engine")
 	ginws := qgin.NewGinEngine(&ctx, &qgin.Config{
 		UseContextMW:       true,
@@ -57,7 +70,7 @@ func RunServer(cfg *config.Config) error {
 	})
 
 	zaplog.InfoC(ctx, "setting up routes")
-	handlers.SetupRoutes(ginws, downloadService)
+	handlers.SetupRoutes(ginws, cfg, downloadService)
 
 	zaplog.InfoC(ctx, fmt.Sprintf("serving on port %d", cfg.Ports.Downloader))
 	return http.ListenAndServe(fmt.Sprintf(":%d", cfg.Ports.Downloader), ginws)
@@ -14,6 +14,9 @@ type Config struct {
 	Spotify     struct {
 		ClientID     string `yaml:"clientID"`
 		ClientSecret string `yaml:"clientSecret"`
+		// SearchCacheTTL is how many hours search results are reused for.
+		// 0 disables the cache.
+		SearchCacheTTL int `yaml:"searchCacheTTL"`
 	} `yaml:"spotify"`
 	BaseURL string `yaml:"baseURL"`
 	Ports   struct {
@@ -24,12 +27,120 @@ type Config struct {
 	Endpoints struct {
 		Genre string `yaml:"genre"`
 		Meta  string `yaml:"meta"`
+		ATV   string `yaml:"atv"`
 	} `yaml:"endpoints"`
+	Download struct {
+		PreferATV bool `yaml:"preferATV"`
+		// PathTemplate lays out saved files under DownloadDir, e.g.
+		// "{albumartist}/[{year} - ]{album}/{track:02} {title}.{ext}".
+		PathTemplate string `yaml:"pathTemplate"`
+		// PlaylistTemplates overrides PathTemplate for the tracks of a
+		// playlist, keyed by playlist ID.
+		PlaylistTemplates map[string]string `yaml:"playlistTemplates"`
+		// CollisionPolicy decides what happens when a file is saved to a
+		// path another track's file has: suffix, replace-if-better or skip.
+		CollisionPolicy string `yaml:"collisionPolicy"`
+	} `yaml:"download"`
+	Segments struct {
+		// Enabled removes the segments a SponsorBlock compatible segment
+		// database lists for a video, such as sponsor reads and non-music
+		// intros, while converting.
+		Enabled  bool   `yaml:"enabled"`
+		Endpoint string `yaml:"endpoint"`
+		// Categories are the segment categories to remove, music_offtopic
+		// when empty.
+		Categories []string `yaml:"categories"`
+	} `yaml:"segments"`
+	Lyrics struct {
+		// Enabled embeds the lyrics from a video's captions.
+		Enabled bool `yaml:"enabled"`
+		// Languages are the caption languages to prefer, in order, e.g. en.
+		// Any other language is used when none of them is available.
+		Languages []string `yaml:"languages"`
+		// AutoGenerated allows captions made by speech recognition.
+		AutoGenerated bool `yaml:"autoGenerated"`
+		// Sidecar writes the synced lyrics to a .lrc file next to each
+		// saved file.
+		Sidecar bool `yaml:"sidecar"`
+	} `yaml:"lyrics"`
+	Fingerprint struct {
+		Enabled    bool   `yaml:"enabled"`
+		FPCalcPath string `yaml:"fpcalcPath"`
+		// Threshold is the share of matching fingerprint bits, from 0 to 1,
+		// above which two tracks are the same recording.
+		Threshold float64 `yaml:"threshold"`
+		// DuplicatePolicy decides what happens when a download is the same
+		// recording as a saved track: skip, replace or keep.
+		DuplicatePolicy string `yaml:"duplicatePolicy"`
+	} `yaml:"fingerprint"`
+	Reconcile struct {
+		// Interval is how many minutes pass between checks of the library
+		// against the database. 0 only checks when asked through the API.
+		Interval int `yaml:"interval"`
+		// Requeue downloads tracks whose file has gone missing again.
+		Requeue bool `yaml:"requeue"`
+	} `yaml:"reconcile"`
 	Concurrency struct {
 		Download   int `yaml:"download"`
 		Conversion int `yaml:"conversion"`
 		Genre      int `yaml:"genre"`
 	} `yaml:"concurrency"`
+	Matching struct {
+		Threshold         float64 `yaml:"threshold"`
+		DurationTolerance int     `yaml:"durationTolerance"`
+	} `yaml:"matching"`
+	Review struct {
+		Enabled    bool    `yaml:"enabled"`
+		Threshold  float64 `yaml:"threshold"`  // matches scoring below this are parked for review, the match threshold when 0
+		Candidates int     `yaml:"candidates"` // how many candidates are kept per review
+		PendingDir string  `yaml:"pendingDir"`
+	} `yaml:"review"`
+	Metadata struct {
+		Providers  []ProviderConfig `yaml:"providers"`
+		FeatPolicy string           `yaml:"featPolicy"` // title, artist or both
+	} `yaml:"metadata"`
+	CoverArt struct {
+		MaxSize  int    `yaml:"maxSize"`  // longest edge in pixels
+		Quality  int    `yaml:"quality"`  // JPEG quality, 1-100
+		MaxBytes int64  `yaml:"maxBytes"` // largest download accepted
+		Timeout  int    `yaml:"timeout"`  // seconds
+		CacheDir string `yaml:"cacheDir"`
+	} `yaml:"coverArt"`
+	MusicBrainz struct {
+		Endpoint         string `yaml:"endpoint"`
+		CoverArtEndpoint string `yaml:"coverArtEndpoint"`
+		UserAgent        string `yaml:"userAgent"`
+		RequestInterval  int    `yaml:"requestInterval"` // minimum milliseconds between requests
+	} `yaml:"musicBrainz"`
+	AcoustID struct {
+		Endpoint string  `yaml:"endpoint"`
+		APIKey   string  `yaml:"apiKey"`
+		MinScore float64 `yaml:"minScore"` // lowest AcoustID score a fingerprint match is used at
+	} `yaml:"acoustID"`
+	Rewrite struct {
+		Rules []RewriteRule `yaml:"rules"`
+	} `yaml:"rewrite"`
+}
+
+// ProviderConfig enables a metadata provider and sets how much its results
+// are trusted relative to the other providers in the chain.
+type ProviderConfig struct {
+	Name       string  `yaml:"name"`
+	Enabled    bool    `yaml:"enabled"`
+	Confidence float64 `yaml:"confidence"`
+}
+
+// RewriteRule cleans or classifies YouTube titles and artists. Rules run in
+// order, each on the output of the one before. Match is a Go regular
+// expression; a named group "artist" in a cover rule captures the cover
+// artist. Replace uses regexp expansion syntax and, when left out, the text
+// is only classified.
+type RewriteRule struct {
+	Name     string  `yaml:"name" json:"name"`
+	Field    string  `yaml:"field" json:"field"` // title or artist
+	Match    string  `yaml:"match" json:"match"`
+	Replace  *string `yaml:"replace" json:"replace"`
+	Classify string  `yaml:"classify" json:"classify"` // cover, remix, live or instrumental
 }
 
 func LoadConfigFromFile(path string) (*Config, error) {
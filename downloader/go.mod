@@ -3,16 +3,20 @@ module github.com/gcottom/yt-dl-services/downloader
 go 1.22.3
 
 require (
+	github.com/bogem/id3v2/v2 v2.1.4
 	github.com/gcottom/go-zaplog v0.0.3
 	github.com/gcottom/mp3meta v0.0.0-20240614011545-57dbee245b0d
 	github.com/gcottom/qgin v0.0.4
 	github.com/gcottom/retry v0.1.1
 	github.com/gcottom/semaphore v0.0.2
 	github.com/gin-gonic/gin v1.10.0
+	github.com/google/uuid v1.6.0
 	github.com/kkdai/youtube/v2 v2.10.1
 	github.com/zmb3/spotify/v2 v2.4.2
 	go.uber.org/zap v1.27.0
+	golang.org/x/image v0.18.0
 	golang.org/x/oauth2 v0.21.0
+	golang.org/x/text v0.16.0
 	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.31.1
 )
@@ -20,7 +24,6 @@ require (
 require (
 	github.com/aler9/writerseeker v1.1.0 // indirect
 	github.com/bitly/go-simplejson v0.5.1 // indirect
-	github.com/bogem/id3v2/v2 v2.1.4 // indirect
 	github.com/bytedance/sonic v1.11.6 // indirect
 	github.com/bytedance/sonic/loader v0.1.1 // indirect
 	github.com/cloudwego/base64x v0.1.4 // indirect
@@ -36,7 +39,6 @@ require (
 	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
 	github.com/google/pprof v0.0.0-20240409012703-83162a5b38cd // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.7 // indirect
@@ -54,7 +56,6 @@ require (
 	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
-	golang.org/x/text v0.15.0 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
 	modernc.org/gc/v3 v3.0.0-20240107210532-573471604cb6 // indirect
 	modernc.org/libc v1.55.3 // indirect
@@ -3,8 +3,12 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"io"
+	"strconv"
 
 	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/download"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -18,7 +22,15 @@ func (h *Handler) StartDownload(ctx *gin.Context) {
 	}
 	zaplog.InfoC(ctx, "start download request received", zap.String("id", id))
 
-	if err := h.DownloadService.InitiateDownload(ctx, id); err != nil {
+	options, err := h.downloadOptions(ctx)
+	if err != nil {
+		zaplog.WarnC(ctx, "start download request with invalid options", zap.Error(err))
+		ResponseFailure(ctx, err)
+		return
+	}
+	request := download.DownloadRequest{ID: id, Options: options}
+
+	if err := h.DownloadService.InitiateDownload(ctx, request); err != nil {
 		zaplog.ErrorC(ctx, "failed to start download", zap.String("id", id), zap.Error(err))
 		ResponseInternalError(ctx, fmt.Errorf("failed to start download: %w", err))
 		return
@@ -27,3 +39,230 @@ func (h *Handler) StartDownload(ctx *gin.Context) {
 	zaplog.InfoC(ctx, "start download request queued successfully", zap.String("id", id))
 	ResponseSuccess(ctx, StartDownloadResponse{State: "ACK"})
 }
+
+func (h *Handler) ListReviews(ctx *gin.Context) {
+	reviews, err := h.DownloadService.ListReviews(ctx)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to list reviews", zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to list reviews: %w", err))
+		return
+	}
+	ResponseSuccess(ctx, ReviewListResponse{Reviews: reviews})
+}
+
+func (h *Handler) ResolveReview(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var choice download.ReviewChoice
+	if err := ctx.ShouldBindJSON(&choice); err != nil {
+		zaplog.WarnC(ctx, "resolve review request with invalid body", zap.String("id", id), zap.Error(err))
+		ResponseFailure(ctx, fmt.Errorf("invalid review choice: %w", err))
+		return
+	}
+	zaplog.InfoC(ctx, "resolve review request received", zap.String("id", id))
+
+	if err := h.DownloadService.ResolveReview(ctx, id, choice); err != nil {
+		if errors.Is(err, download.ErrReviewNotFound) || errors.Is(err, download.ErrInvalidChoice) {
+			zaplog.WarnC(ctx, "rejected review choice", zap.String("id", id), zap.Error(err))
+			ResponseFailure(ctx, err)
+			return
+		}
+		zaplog.ErrorC(ctx, "failed to resolve review", zap.String("id", id), zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to resolve review: %w", err))
+		return
+	}
+
+	zaplog.InfoC(ctx, "review resolved successfully", zap.String("id", id))
+	ResponseSuccess(ctx, ResolveReviewResponse{State: "DONE"})
+}
+
+func (h *Handler) ResolveMeta(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		zaplog.WarnC(ctx, "resolve request without ID present: ID is required")
+		ResponseFailure(ctx, errors.New("resolve request without ID present: ID is required"))
+		return
+	}
+	options, err := h.downloadOptions(ctx)
+	if err != nil {
+		zaplog.WarnC(ctx, "resolve request with invalid options", zap.Error(err))
+		ResponseFailure(ctx, err)
+		return
+	}
+	zaplog.InfoC(ctx, "resolve request received", zap.String("id", id))
+
+	explanation, err := h.DownloadService.ExplainTrack(ctx, download.DownloadRequest{ID: id, Options: options})
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to resolve meta", zap.String("id", id), zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to resolve meta: %w", err))
+		return
+	}
+	ResponseSuccess(ctx, explanation)
+}
+
+func (h *Handler) PreviewRewrite(ctx *gin.Context) {
+	var preview download.RewritePreview
+	if err := ctx.ShouldBindJSON(&preview); err != nil {
+		zaplog.WarnC(ctx, "rewrite preview request with invalid body", zap.Error(err))
+		ResponseFailure(ctx, fmt.Errorf("invalid rewrite preview: %w", err))
+		return
+	}
+	result, err := h.DownloadService.PreviewRewrite(ctx, preview)
+	if err != nil {
+		if errors.Is(err, meta.ErrInvalidRewriteRule) {
+			zaplog.WarnC(ctx, "rejected rewrite rules", zap.Error(err))
+			ResponseFailure(ctx, err)
+			return
+		}
+		zaplog.ErrorC(ctx, "failed to preview rewrite", zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to preview rewrite: %w", err))
+		return
+	}
+	ResponseSuccess(ctx, result)
+}
+
+// downloadOptions reads the per-request download options from the query,
+// defaulting to the configured behaviour.
+func (h *Handler) downloadOptions(ctx *gin.Context) (download.DownloadOptions, error) {
+	options := download.DownloadOptions{PreferATV: h.Config.Download.PreferATV}
+	if atv := ctx.Query("atv"); atv != "" {
+		preferATV, err := strconv.ParseBool(atv)
+		if err != nil {
+			return options, fmt.Errorf("invalid atv flag: %w", err)
+		}
+		options.PreferATV = preferATV
+	}
+	if template := ctx.Query("template"); template != "" {
+		if _, err := download.ParsePathTemplate(template); err != nil {
+			return options, err
+		}
+		options.PathTemplate = template
+	}
+	return options, nil
+}
+
+func (h *Handler) StartRetag(ctx *gin.Context) {
+	target := ctx.Query("id")
+	if target == "" {
+		zaplog.WarnC(ctx, "retag request without ID present: ID is required")
+		ResponseFailure(ctx, errors.New("retag request without ID present: a track ID, playlist ID or \"all\" is required"))
+		return
+	}
+	zaplog.InfoC(ctx, "retag request received", zap.String("id", target))
+
+	jobID, err := h.DownloadService.StartRetag(ctx, target)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to start retag", zap.String("id", target), zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to start retag: %w", err))
+		return
+	}
+
+	zaplog.InfoC(ctx, "retag request queued successfully", zap.String("id", target), zap.String("jobID", jobID))
+	ResponseSuccess(ctx, StartJobResponse{State: "ACK", JobID: jobID})
+}
+
+func (h *Handler) StartReconcile(ctx *gin.Context) {
+	zaplog.InfoC(ctx, "reconcile request received")
+	jobID, err := h.DownloadService.StartReconcile(ctx)
+	if errors.Is(err, download.ErrReconcileBusy) {
+		zaplog.WarnC(ctx, "reconcile queue is full")
+		ResponseFailure(ctx, err)
+		return
+	}
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to start reconcile", zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to start reconcile: %w", err))
+		return
+	}
+
+	zaplog.InfoC(ctx, "reconcile request queued successfully", zap.String("jobID", jobID))
+	ResponseSuccess(ctx, StartJobResponse{State: "ACK", JobID: jobID})
+}
+
+func (h *Handler) GetReconcileReport(ctx *gin.Context) {
+	report, err := h.DownloadService.GetReconcileReport(ctx)
+	if err != nil {
+		zaplog.WarnC(ctx, "reconcile report requested before any reconcile finished")
+		ResponseFailure(ctx, err)
+		return
+	}
+	ResponseSuccess(ctx, report)
+}
+
+func (h *Handler) ListDuplicates(ctx *gin.Context) {
+	groups, err := h.DownloadService.ListDuplicates(ctx)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to list duplicates", zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to list duplicates: %w", err))
+		return
+	}
+	ResponseSuccess(ctx, DuplicateListResponse{Duplicates: groups})
+}
+
+func (h *Handler) GetStatus(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		ResponseSuccess(ctx, JobListResponse{Jobs: h.DownloadService.ListJobs(ctx)})
+		return
+	}
+	job, err := h.DownloadService.GetJob(ctx, id)
+	if err != nil {
+		zaplog.WarnC(ctx, "status request for unknown job", zap.String("id", id))
+		ResponseFailure(ctx, err)
+		return
+	}
+	ResponseSuccess(ctx, job)
+}
+
+func (h *Handler) SetOverride(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var override download.MetaOverride
+	if err := ctx.ShouldBind(&override); err != nil {
+		zaplog.WarnC(ctx, "override request with invalid body", zap.String("id", id), zap.Error(err))
+		ResponseFailure(ctx, fmt.Errorf("invalid override: %w", err))
+		return
+	}
+	coverArt, err := h.uploadedCoverArt(ctx)
+	if err != nil {
+		zaplog.WarnC(ctx, "override request with invalid cover art", zap.String("id", id), zap.Error(err))
+		ResponseFailure(ctx, err)
+		return
+	}
+	override.CoverArt = coverArt
+	zaplog.InfoC(ctx, "override request received", zap.String("id", id))
+
+	if err := h.DownloadService.SetOverride(ctx, id, override); err != nil {
+		if errors.Is(err, download.ErrInvalidOverride) {
+			zaplog.WarnC(ctx, "rejected override", zap.String("id", id), zap.Error(err))
+			ResponseFailure(ctx, err)
+			return
+		}
+		zaplog.ErrorC(ctx, "failed to set override", zap.String("id", id), zap.Error(err))
+		ResponseInternalError(ctx, fmt.Errorf("failed to set override: %w", err))
+		return
+	}
+
+	zaplog.InfoC(ctx, "override set successfully", zap.String("id", id))
+	ResponseSuccess(ctx, SetOverrideResponse{State: "DONE"})
+}
+
+// uploadedCoverArt reads the optional coverArt file of a multipart request.
+func (h *Handler) uploadedCoverArt(ctx *gin.Context) ([]byte, error) {
+	header, err := ctx.FormFile("coverArt")
+	if err != nil {
+		// JSON bodies and forms without an image carry no upload.
+		return nil, nil
+	}
+	maxBytes := h.Config.CoverArt.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 10 << 20
+	}
+	if header.Size > maxBytes {
+		return nil, fmt.Errorf("cover art is larger than %d bytes", maxBytes)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cover art: %w", err)
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxBytes))
+}
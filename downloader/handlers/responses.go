@@ -1,6 +1,10 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gcottom/yt-dl-services/downloader/services/download"
+	"github.com/gcottom/yt-dl-services/downloader/services/jobs"
+	"github.com/gin-gonic/gin"
+)
 
 type Failure struct {
 	Error string `json:"error"`
@@ -10,6 +14,31 @@ type StartDownloadResponse struct {
 	State string `json:"state"`
 }
 
+type StartJobResponse struct {
+	State string `json:"state"`
+	JobID string `json:"jobID"`
+}
+
+type JobListResponse struct {
+	Jobs []jobs.Job `json:"jobs"`
+}
+
+type ResolveReviewResponse struct {
+	State string `json:"state"`
+}
+
+type SetOverrideResponse struct {
+	State string `json:"state"`
+}
+
+type DuplicateListResponse struct {
+	Duplicates []download.DuplicateGroup `json:"duplicates"`
+}
+
+type ReviewListResponse struct {
+	Reviews []download.Review `json:"reviews"`
+}
+
 func ResponseFailure(ctx *gin.Context, err error) {
 	ctx.JSON(400, Failure{err.Error()})
 }
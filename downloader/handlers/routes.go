@@ -1,14 +1,24 @@
 package handlers
 
 import (
+	"github.com/gcottom/yt-dl-services/downloader/config"
 	"github.com/gcottom/yt-dl-services/downloader/services/download"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, downloadService download.DownloadService) {
-	h := &Handler{DownloadService: downloadService}
+func SetupRoutes(router *gin.Engine, cfg *config.Config, downloadService download.DownloadService) {
+	h := &Handler{Config: cfg, DownloadService: downloadService}
 
 	router.Group("/api").
 		GET("/download", h.StartDownload).
-		GET("/status", func(ctx *gin.Context) {})
+		GET("/status", h.GetStatus).
+		POST("/retag", h.StartRetag).
+		GET("/resolve", h.ResolveMeta).
+		POST("/rewrite", h.PreviewRewrite).
+		GET("/review", h.ListReviews).
+		POST("/review/:id", h.ResolveReview).
+		PUT("/tracks/:id/meta", h.SetOverride).
+		POST("/reconcile", h.StartReconcile).
+		GET("/reconcile", h.GetReconcileReport).
+		GET("/duplicates", h.ListDuplicates)
 }
@@ -1,7 +1,11 @@
 package handlers
 
-import "github.com/gcottom/yt-dl-services/downloader/services/download"
+import (
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/services/download"
+)
 
 type Handler struct {
+	Config          *config.Config
 	DownloadService download.DownloadService
 }
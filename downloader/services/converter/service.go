@@ -5,16 +5,24 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/segments"
 	"go.uber.org/zap"
 )
 
-func (s *Service) Convert(ctx context.Context, id string) error {
-	var args = []string{"-i", fmt.Sprintf("%s/%s.temp", s.Config.TempDir, id), "-c:a", "libmp3lame", "-b:a", "256k", "-f", "mp3", fmt.Sprintf("./data/%s.mp3", id)}
+// Convert transcodes the downloaded audio of a video to mp3, leaving out the
+// cut segments.
+func (s *Service) Convert(ctx context.Context, id string, cuts []segments.Segment) error {
+	var args = []string{"-i", fmt.Sprintf("%s/%s.temp", s.Config.TempDir, id)}
+	if len(cuts) > 0 {
+		args = append(args, "-af", cutFilter(cuts))
+	}
+	args = append(args, "-c:a", "libmp3lame", "-b:a", "256k", "-f", "mp3", fmt.Sprintf("./data/%s.mp3", id))
 	cmd := exec.Command(s.Config.FFMPEGPath, args...)
 
-	zaplog.InfoC(ctx, "converting file", zap.String("id", id))
+	zaplog.InfoC(ctx, "converting file", zap.String("id", id), zap.Int("cuts", len(cuts)))
 	err := cmd.Start() // Start a process on another goroutine
 	if err != nil {
 		zaplog.ErrorC(ctx, "conversion error", zap.Error(err))
@@ -33,3 +41,13 @@ func (s *Service) Convert(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// cutFilter returns the ffmpeg audio filter that drops the samples inside the
+// cut segments and closes the gaps they leave.
+func cutFilter(cuts []segments.Segment) string {
+	between := make([]string, 0, len(cuts))
+	for _, cut := range cuts {
+		between = append(between, fmt.Sprintf("between(t,%.3f,%.3f)", float64(cut.Start)/1000, float64(cut.End)/1000))
+	}
+	return fmt.Sprintf("aselect='not(%s)',asetpts=N/SR/TB", strings.Join(between, "+"))
+}
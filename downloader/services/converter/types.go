@@ -4,10 +4,11 @@ import (
 	"context"
 
 	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/services/segments"
 )
 
 type ConverterService interface {
-	Convert(ctx context.Context, id string) error
+	Convert(ctx context.Context, id string, cuts []segments.Segment) error
 }
 
 type Service struct {
@@ -0,0 +1,147 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/fingerprint"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+// What finalizeTrack does when a download is the same recording as a saved
+// track.
+const (
+	DuplicateSkip    = "skip"    // do not save the download
+	DuplicateReplace = "replace" // save the download in place of the saved track's file
+	DuplicateKeep    = "keep"    // save both
+)
+
+const defaultFingerprintThreshold = 0.8
+
+// maxDurationDifference is how many milliseconds apart the lengths of two
+// uploads of the same recording may be. Fingerprints of tracks further apart
+// are not compared.
+const maxDurationDifference = 30000
+
+// fingerprintTrack fingerprints a converted file when fingerprinting is
+// enabled. A file that cannot be fingerprinted is saved without one.
+func (s *Service) fingerprintTrack(ctx context.Context, track track_sql.Track, path string) track_sql.Track {
+	if !s.Config.Fingerprint.Enabled {
+		return track
+	}
+	fp, err := s.Fingerprints.Calculate(ctx, path)
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to fingerprint track", zap.String("id", track.ID), zap.Error(err))
+		return track
+	}
+	track.Fingerprint = fingerprint.Encode(fp.Raw)
+	if track.Duration == 0 {
+		// Duplicates are only looked for among tracks of about the same
+		// length.
+		track.Duration = int(fp.Duration * 1000)
+	}
+	return track
+}
+
+// findDuplicate returns the saved track that is the same recording as track,
+// if there is one. Only the fingerprints of tracks of about the same length
+// are compared.
+func (s *Service) findDuplicate(ctx context.Context, track track_sql.Track) (track_sql.Track, bool) {
+	if track.Fingerprint == "" || track.Duration <= 0 {
+		return track_sql.Track{}, false
+	}
+	raw, err := fingerprint.Decode(track.Fingerprint)
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to decode fingerprint", zap.String("id", track.ID), zap.Error(err))
+		return track_sql.Track{}, false
+	}
+	tracks, err := s.TrackSQL.ListFingerprintedTracks(ctx, track.ID, track.Duration, maxDurationDifference)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to list fingerprinted tracks", zap.Error(err))
+		return track_sql.Track{}, false
+	}
+	threshold := s.Config.Fingerprint.Threshold
+	if threshold <= 0 {
+		threshold = defaultFingerprintThreshold
+	}
+	var duplicate track_sql.Track
+	best := threshold
+	for _, saved := range tracks {
+		savedRaw, err := fingerprint.Decode(saved.Fingerprint)
+		if err != nil {
+			continue
+		}
+		if similarity := fingerprint.Similarity(raw, savedRaw); similarity >= best {
+			duplicate, best = saved, similarity
+		}
+	}
+	if duplicate.ID == "" {
+		return track_sql.Track{}, false
+	}
+	zaplog.InfoC(ctx, "download is a duplicate", zap.String("id", track.ID), zap.String("duplicateOf", duplicate.ID), zap.Float64("similarity", best))
+	return duplicate, true
+}
+
+func (s *Service) duplicatePolicy() string {
+	switch policy := s.Config.Fingerprint.DuplicatePolicy; policy {
+	case DuplicateSkip, DuplicateReplace:
+		return policy
+	}
+	return DuplicateKeep
+}
+
+// releaseDuplicate removes the file of a track replaced by a download of the
+// same recording and records what replaced it.
+func (s *Service) releaseDuplicate(ctx context.Context, duplicate track_sql.Track, replacedBy string, path string) error {
+	if duplicate.Path != "" && duplicate.Path != path {
+		if err := os.Remove(duplicate.Path); err != nil && !os.IsNotExist(err) {
+			zaplog.ErrorC(ctx, "failed to remove replaced file", zap.String("path", duplicate.Path), zap.Error(err))
+		}
+		if err := os.Remove(lyricsPath(duplicate.Path)); err != nil && !os.IsNotExist(err) {
+			zaplog.ErrorC(ctx, "failed to remove replaced lyrics", zap.String("path", lyricsPath(duplicate.Path)), zap.Error(err))
+		}
+	}
+	duplicate.Done = 0
+	duplicate.Path = ""
+	duplicate.Error = 1
+	duplicate.ErrorMessage = fmt.Sprintf("file replaced by %s, a download of the same recording", replacedBy)
+	duplicate.DuplicateOf = replacedBy
+	return s.TrackSQL.UpdateTrack(ctx, duplicate)
+}
+
+// ListDuplicates groups the tracks found to be the same recording by the
+// track they duplicate.
+func (s *Service) ListDuplicates(ctx context.Context) ([]DuplicateGroup, error) {
+	tracks, err := s.TrackSQL.ListDuplicates(ctx)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to list duplicates", zap.Error(err))
+		return nil, err
+	}
+	groups := make([]DuplicateGroup, 0)
+	for _, track := range tracks {
+		if len(groups) == 0 || groups[len(groups)-1].Original.ID != track.DuplicateOf {
+			original := DuplicateTrack{ID: track.DuplicateOf}
+			if saved, err := s.TrackSQL.GetTrack(ctx, track.DuplicateOf); err == nil {
+				original = duplicateTrack(saved)
+			}
+			groups = append(groups, DuplicateGroup{Original: original, Duplicates: make([]DuplicateTrack, 0)})
+		}
+		group := &groups[len(groups)-1]
+		group.Duplicates = append(group.Duplicates, duplicateTrack(track))
+	}
+	return groups, nil
+}
+
+func duplicateTrack(track track_sql.Track) DuplicateTrack {
+	return DuplicateTrack{ID: track.ID, Title: track.Title, Artist: track.Artist, Path: track.Path, Saved: track.Done == 1}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
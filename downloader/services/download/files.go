@@ -0,0 +1,99 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+// What saveFile does when the rendered path is taken by another track's
+// file.
+const (
+	CollisionSuffix        = "suffix"            // keep both, numbering the new file
+	CollisionReplaceBetter = "replace-if-better" // replace when the new file was downloaded at a higher bitrate
+	CollisionSkip          = "skip"              // keep the existing file and do not save
+)
+
+var ErrPathTaken = errors.New("path is taken by another file")
+
+// claimPath returns the path a track's file is written to, applying the
+// configured collision policy when path already holds another file. The
+// file the track was saved to before may always be replaced.
+func (s *Service) claimPath(ctx context.Context, path string, track track_sql.Track) (string, error) {
+	if path == track.Path {
+		return path, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path, nil
+	}
+	switch s.Config.Download.CollisionPolicy {
+	case CollisionSkip:
+		return "", fmt.Errorf("%w: %s", ErrPathTaken, path)
+	case CollisionReplaceBetter:
+		// Every download is converted at the same bitrate, so quality is
+		// judged by the stream the audio was converted from. A file no
+		// track was saved to is never replaced.
+		existing, err := s.TrackSQL.GetTrackByPath(ctx, path)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%w: %s is not a downloaded track", ErrPathTaken, path)
+		}
+		if err != nil {
+			zaplog.ErrorC(ctx, "failed to get track by path", zap.String("path", path), zap.Error(err))
+			return "", err
+		}
+		if track.Bitrate <= existing.Bitrate {
+			return "", fmt.Errorf("%w: %s was downloaded at %d bps, this download at %d bps", ErrPathTaken, path, existing.Bitrate, track.Bitrate)
+		}
+		zaplog.InfoC(ctx, "replacing lower quality file", zap.String("id", track.ID), zap.String("path", path), zap.Int("bitrate", existing.Bitrate), zap.Int("newBitrate", track.Bitrate))
+		if err := s.releasePath(ctx, existing, track.ID); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	return availablePath(path, track.Path), nil
+}
+
+// releasePath detaches a file that is about to be replaced from the track it
+// was saved for, so that track no longer claims to be in the library.
+func (s *Service) releasePath(ctx context.Context, track track_sql.Track, replacedBy string) error {
+	track.Done = 0
+	track.Path = ""
+	track.Error = 1
+	track.ErrorMessage = fmt.Sprintf("file replaced by a higher quality download of %s", replacedBy)
+	return s.TrackSQL.UpdateTrack(ctx, track)
+}
+
+// availablePath returns path, or path with a number added to the name when
+// another file already has it. own is the file the track was saved to before,
+// which may be replaced.
+func availablePath(path string, own string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidate := path
+	for n := 2; ; n++ {
+		if candidate == own {
+			return candidate
+		}
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+}
+
+// contentHash identifies the contents of a saved file, so the file can be
+// recognised after it has been moved.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
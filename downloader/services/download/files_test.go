@@ -0,0 +1,100 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+)
+
+func writeFiles(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAvailablePath(t *testing.T) {
+	dir := t.TempDir()
+	taken := filepath.Join(dir, "taken.mp3")
+	twice := filepath.Join(dir, "twice.mp3")
+	writeFiles(t, taken, twice, filepath.Join(dir, "twice (2).mp3"))
+	tests := []struct {
+		name string
+		path string
+		own  string
+		want string
+	}{
+		{"free", filepath.Join(dir, "free.mp3"), "", filepath.Join(dir, "free.mp3")},
+		{"taken", taken, "", filepath.Join(dir, "taken (2).mp3")},
+		{"taken twice", twice, "", filepath.Join(dir, "twice (3).mp3")},
+		{"own file", taken, taken, taken},
+		{"own numbered file", twice, filepath.Join(dir, "twice (2).mp3"), filepath.Join(dir, "twice (2).mp3")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := availablePath(tt.path, tt.own); got != tt.want {
+				t.Errorf("availablePath(%q, %q) = %q, want %q", tt.path, tt.own, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimPath(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DBPath: filepath.Join(dir, "tracks.db")}
+	trackSQL, err := track_sql.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := zaplog.CreateAndInject(context.Background())
+	saved := filepath.Join(dir, "saved.mp3")
+	untracked := filepath.Join(dir, "untracked.mp3")
+	writeFiles(t, saved, untracked)
+	if err := trackSQL.InsertTrack(ctx, track_sql.Track{ID: "old", Title: "Song", Done: 1, Path: saved, Bitrate: 128000}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		policy       string
+		path         string
+		track        track_sql.Track
+		want         string
+		wantErr      error
+		wantReleased bool
+	}{
+		{"free path", CollisionSkip, filepath.Join(dir, "free.mp3"), track_sql.Track{ID: "new"}, filepath.Join(dir, "free.mp3"), nil, false},
+		{"own file", CollisionSkip, saved, track_sql.Track{ID: "old", Path: saved}, saved, nil, false},
+		{"suffix", CollisionSuffix, saved, track_sql.Track{ID: "new"}, filepath.Join(dir, "saved (2).mp3"), nil, false},
+		{"suffix by default", "", saved, track_sql.Track{ID: "new"}, filepath.Join(dir, "saved (2).mp3"), nil, false},
+		{"skip", CollisionSkip, saved, track_sql.Track{ID: "new"}, "", ErrPathTaken, false},
+		{"replace with a lower bitrate", CollisionReplaceBetter, saved, track_sql.Track{ID: "new", Bitrate: 64000}, "", ErrPathTaken, false},
+		{"replace with the same bitrate", CollisionReplaceBetter, saved, track_sql.Track{ID: "new", Bitrate: 128000}, "", ErrPathTaken, false},
+		{"replace an untracked file", CollisionReplaceBetter, untracked, track_sql.Track{ID: "new", Bitrate: 256000}, "", ErrPathTaken, false},
+		{"replace with a higher bitrate", CollisionReplaceBetter, saved, track_sql.Track{ID: "new", Bitrate: 256000}, saved, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Download.CollisionPolicy = tt.policy
+			s := &Service{Config: cfg, TrackSQL: trackSQL}
+			got, err := s.claimPath(ctx, tt.path, tt.track)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Fatalf("claimPath(%q) = %q, %v, want %q, %v", tt.path, got, err, tt.want, tt.wantErr)
+			}
+			old, err := trackSQL.GetTrack(ctx, "old")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if released := old.Path == ""; released != tt.wantReleased {
+				t.Errorf("claimPath(%q) released the replaced track = %v, want %v", tt.path, released, tt.wantReleased)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package download
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+// RebuildReport is the outcome of rebuilding the track table from the files
+// in the download directory.
+type RebuildReport struct {
+	Scanned    int      `json:"scanned"`
+	Added      int      `json:"added"`      // tracks that were not in the table
+	Updated    int      `json:"updated"`    // tracks whose path or status was corrected
+	Unchanged  int      `json:"unchanged"`  // tracks the table already had right
+	Untracked  []string `json:"untracked"`  // files without a YOUTUBE_ID tag
+	Duplicates []string `json:"duplicates"` // further files tagged with an ID already seen
+	Missing    []string `json:"missing"`    // saved tracks whose file was not found
+	Failed     []string `json:"failed"`     // files that could not be read
+	Skipped    []string `json:"skipped"`    // files moved or removed by a save while the rebuild ran
+}
+
+// RebuildLibrary walks the download directory and brings the track table in
+// line with the provenance tags of the files in it. Tracks are added for
+// files the table does not know and pointed at the file they were found in;
+// saved tracks whose file is gone are only reported. Files are read without
+// holding the save lock; it is only taken to check and record each track.
+func (s *Service) RebuildLibrary(ctx context.Context) (RebuildReport, error) {
+	report := RebuildReport{Untracked: make([]string, 0), Duplicates: make([]string, 0), Missing: make([]string, 0), Failed: make([]string, 0), Skipped: make([]string, 0)}
+	files, err := s.libraryFiles()
+	if err != nil {
+		return report, err
+	}
+	found := make(map[string]bool)
+	for _, path := range files {
+		report.Scanned++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			zaplog.ErrorC(ctx, "failed to read library file", zap.String("path", path), zap.Error(err))
+			report.Failed = append(report.Failed, path)
+			continue
+		}
+		tagged, err := meta.ReadTaggedTrack(data)
+		if err != nil {
+			zaplog.WarnC(ctx, "failed to read library file tags", zap.String("path", path), zap.Error(err))
+			report.Failed = append(report.Failed, path)
+			continue
+		}
+		id := tagged.Provenance.VideoID
+		if id == "" {
+			report.Untracked = append(report.Untracked, path)
+			continue
+		}
+		if found[id] {
+			report.Duplicates = append(report.Duplicates, path)
+			continue
+		}
+		found[id] = true
+		if err := s.rebuildTrack(ctx, &report, path, tagged, contentHash(data)); err != nil {
+			return report, err
+		}
+	}
+	tracks, err := s.TrackSQL.ListTracks(ctx)
+	if err != nil {
+		return report, err
+	}
+	// Only tracks that were saved to a recorded path can be missing; queued,
+	// failed and parked tracks never had a file.
+	for _, track := range tracks {
+		if track.Done == 1 && track.Path != "" && !found[track.ID] {
+			report.Missing = append(report.Missing, track.ID)
+		}
+	}
+	return report, nil
+}
+
+// rebuildTrack adds or corrects the track a tagged file was saved for. A file
+// that is no longer at path was moved by a save since it was read, which
+// recorded where it went, and is skipped.
+func (s *Service) rebuildTrack(ctx context.Context, report *RebuildReport, path string, tagged meta.TaggedTrack, hash string) error {
+	s.SaveLock.Lock()
+	defer s.SaveLock.Unlock()
+	if _, err := os.Stat(path); err != nil {
+		zaplog.WarnC(ctx, "library file changed during rebuild", zap.String("path", path), zap.Error(err))
+		report.Skipped = append(report.Skipped, path)
+		return nil
+	}
+	id := tagged.Provenance.VideoID
+	track, err := s.TrackSQL.GetTrack(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		track = track_sql.Track{
+			ID:          id,
+			Title:       tagged.Meta.Title,
+			Artist:      tagged.Meta.Artist,
+			Album:       tagged.Meta.Album,
+			Genre:       tagged.Meta.Genre,
+			Done:        1,
+			MatchScore:  tagged.Provenance.MatchScore,
+			Duration:    tagged.Meta.Duration,
+			SourceID:    tagged.Provenance.SourceID,
+			Path:        path,
+			ContentHash: hash,
+		}
+		if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
+			zaplog.ErrorC(ctx, "failed to insert rebuilt track", zap.String("id", id), zap.Error(err))
+			return err
+		}
+		report.Added++
+		return nil
+	}
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get track", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	if track.Path == path && track.ContentHash == hash && track.Done == 1 && track.Error == 0 {
+		report.Unchanged++
+		return nil
+	}
+	track.Path = path
+	track.ContentHash = hash
+	track.Done = 1
+	track.Error = 0
+	track.ErrorMessage = ""
+	if err := s.TrackSQL.UpdateTrack(ctx, track); err != nil {
+		zaplog.ErrorC(ctx, "failed to update rebuilt track", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	report.Updated++
+	return nil
+}
+
+// libraryFiles lists the saved files under the download directory. Hidden
+// files and directories, such as the temporary files of an unfinished save,
+// are left out.
+func (s *Service) libraryFiles() ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.WalkDir(s.Config.DownloadDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != s.Config.DownloadDir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && filepath.Ext(path) == "."+fileExtension {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,145 @@
+package download
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/fsutil"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+	"github.com/gcottom/yt-dl-services/downloader/services/segments"
+	"github.com/gcottom/yt-dl-services/downloader/services/youtube_v2"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+const lyricsExtension = "lrc"
+
+// addLyrics sets the lyrics of trackMeta from the captions of the video the
+// audio was downloaded from, which are timed with it once shifted past the
+// segments cut out of the audio. When that video has no captions, those of
+// the track's own video are used without their timing. Lyrics that cannot be
+// fetched are left out.
+func (s *Service) addLyrics(ctx context.Context, track track_sql.Track, trackMeta meta.TrackMeta) meta.TrackMeta {
+	if !s.Config.Lyrics.Enabled {
+		return trackMeta
+	}
+	captions, language, found := s.videoCaptions(ctx, track.SourceVideoID())
+	if found {
+		trackMeta.Lyrics = youtube_v2.CaptionsToLRC(cutCaptions(captions, trackSegments(ctx, track)))
+		trackMeta.LyricsLanguage = language
+		return trackMeta
+	}
+	if track.SourceVideoID() == track.ID {
+		return trackMeta
+	}
+	if captions, language, found = s.videoCaptions(ctx, track.ID); found {
+		trackMeta.Lyrics = youtube_v2.CaptionsToText(captions)
+		trackMeta.LyricsLanguage = language
+	}
+	return trackMeta
+}
+
+// videoCaptions returns the captions of the preferred caption track of a
+// video and their language.
+func (s *Service) videoCaptions(ctx context.Context, videoID string) ([]youtube_v2.Caption, string, bool) {
+	tracks, err := s.YoutubeService.GetCaptionTracks(ctx, videoID)
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to get caption tracks", zap.String("videoID", videoID), zap.Error(err))
+		return nil, "", false
+	}
+	track, found := s.captionTrack(tracks)
+	if !found {
+		return nil, "", false
+	}
+	captions, err := s.YoutubeService.GetCaptions(ctx, track)
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to get captions", zap.String("videoID", videoID), zap.String("language", track.LanguageCode), zap.Error(err))
+		return nil, "", false
+	}
+	if len(captions) == 0 {
+		return nil, "", false
+	}
+	return captions, track.LanguageCode, true
+}
+
+// captionTrack picks the caption track to take lyrics from: a track in the
+// first configured language that has one, then any other track. Tracks made
+// by speech recognition are only used when allowed and no other track is
+// available.
+func (s *Service) captionTrack(tracks []youtube_v2.CaptionTrack) (youtube_v2.CaptionTrack, bool) {
+	for _, autoGenerated := range []bool{false, true} {
+		if autoGenerated && !s.Config.Lyrics.AutoGenerated {
+			break
+		}
+		for _, language := range s.Config.Lyrics.Languages {
+			for _, track := range tracks {
+				if track.AutoGenerated == autoGenerated && sameLanguage(track.LanguageCode, language) {
+					return track, true
+				}
+			}
+		}
+		for _, track := range tracks {
+			if track.AutoGenerated == autoGenerated {
+				return track, true
+			}
+		}
+	}
+	return youtube_v2.CaptionTrack{}, false
+}
+
+// sameLanguage reports whether a caption language is the configured one. A
+// configured base language such as en matches regional variants like en-GB.
+func sameLanguage(code string, language string) bool {
+	base, _, _ := strings.Cut(code, "-")
+	return strings.EqualFold(code, language) || strings.EqualFold(base, language)
+}
+
+// cutCaptions moves captions to where they are heard in audio with cuts taken
+// out. Captions shown during a cut are dropped.
+func cutCaptions(captions []youtube_v2.Caption, cuts []segments.Segment) []youtube_v2.Caption {
+	if len(cuts) == 0 {
+		return captions
+	}
+	kept := make([]youtube_v2.Caption, 0, len(captions))
+	for _, caption := range captions {
+		start, heard := segments.Adjust(int(caption.Start.Milliseconds()), cuts)
+		if !heard {
+			continue
+		}
+		caption.Start = time.Duration(start) * time.Millisecond
+		kept = append(kept, caption)
+	}
+	return kept
+}
+
+// lyricsPath returns the path of the .lrc sidecar of a saved file.
+func lyricsPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + lyricsExtension
+}
+
+// saveLyrics writes the synced lyrics of a saved file to its sidecar when
+// configured. A file saved without lyrics, as when it is retagged, keeps the
+// sidecar it had, moved along with it when it was renamed.
+func (s *Service) saveLyrics(ctx context.Context, path string, previous string, trackMeta meta.TrackMeta) {
+	if _, synced := meta.ParseLRC(trackMeta.Lyrics); synced && s.Config.Lyrics.Sidecar {
+		if err := fsutil.WriteFileAtomic(lyricsPath(path), []byte(trackMeta.Lyrics)); err != nil {
+			zaplog.ErrorC(ctx, "failed to write lyrics", zap.String("path", lyricsPath(path)), zap.Error(err))
+		}
+		if previous != "" && previous != path {
+			if err := os.Remove(lyricsPath(previous)); err != nil && !os.IsNotExist(err) {
+				zaplog.ErrorC(ctx, "failed to remove previous lyrics", zap.String("path", lyricsPath(previous)), zap.Error(err))
+			}
+		}
+		return
+	}
+	if previous == "" || previous == path {
+		return
+	}
+	if err := os.Rename(lyricsPath(previous), lyricsPath(path)); err != nil && !os.IsNotExist(err) {
+		zaplog.ErrorC(ctx, "failed to move lyrics", zap.String("from", lyricsPath(previous)), zap.String("to", lyricsPath(path)), zap.Error(err))
+	}
+}
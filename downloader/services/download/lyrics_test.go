@@ -0,0 +1,40 @@
+package download
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gcottom/yt-dl-services/downloader/services/segments"
+	"github.com/gcottom/yt-dl-services/downloader/services/youtube_v2"
+)
+
+func TestCutCaptions(t *testing.T) {
+	captions := []youtube_v2.Caption{
+		{Start: 5 * time.Second, Text: "intro"},
+		{Start: 20 * time.Second, Text: "verse"},
+		{Start: 65 * time.Second, Text: "sponsor"},
+		{Start: 80 * time.Second, Text: "chorus"},
+	}
+	tests := []struct {
+		name string
+		cuts []segments.Segment
+		want []string
+	}{
+		{"no cuts", nil, []string{"intro@5s", "verse@20s", "sponsor@1m5s", "chorus@1m20s"}},
+		{"cut intro", []segments.Segment{{Start: 0, End: 15000}}, []string{"verse@5s", "sponsor@50s", "chorus@1m5s"}},
+		{"two cuts", []segments.Segment{{Start: 0, End: 15000}, {Start: 60000, End: 70000}}, []string{"verse@5s", "chorus@55s"}},
+		{"caption at the end of a cut", []segments.Segment{{Start: 10000, End: 20000}}, []string{"intro@5s", "verse@10s", "sponsor@55s", "chorus@1m10s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, caption := range cutCaptions(captions, tt.cuts) {
+				got = append(got, caption.Text+"@"+caption.Start.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cutCaptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
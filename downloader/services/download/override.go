@@ -0,0 +1,132 @@
+package download
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidOverride = errors.New("invalid metadata override")
+
+// SetOverride stores metadata set by hand for a track and applies it to the
+// saved file straight away. Fields left empty keep what earlier overrides
+// set. The override is applied again whenever the track is downloaded or
+// retagged.
+func (s *Service) SetOverride(ctx context.Context, id string, override MetaOverride) error {
+	if override.Title == "" && override.Artist == "" && override.Album == "" && override.Genre == "" && override.Year == 0 && override.CoverArtURL == "" && len(override.CoverArt) == 0 {
+		return fmt.Errorf("%w: no fields set", ErrInvalidOverride)
+	}
+	if override.Year < 0 {
+		return fmt.Errorf("%w: year %d", ErrInvalidOverride, override.Year)
+	}
+	stored, err := s.TrackSQL.GetOverride(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		zaplog.ErrorC(ctx, "failed to get override", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	stored.ID = id
+	stored.Title = cmp.Or(override.Title, stored.Title)
+	stored.Artist = cmp.Or(override.Artist, stored.Artist)
+	stored.Album = cmp.Or(override.Album, stored.Album)
+	stored.Genre = cmp.Or(override.Genre, stored.Genre)
+	stored.Year = cmp.Or(override.Year, stored.Year)
+	// New cover art replaces the old, whether it was uploaded or fetched.
+	// Art at a URL is fetched now so that a bad URL is refused rather than
+	// stored.
+	switch {
+	case len(override.CoverArt) > 0:
+		coverArt, err := s.MetaService.NormalizeCoverArt(override.CoverArt)
+		if err != nil {
+			return fmt.Errorf("%w: cover art: %v", ErrInvalidOverride, err)
+		}
+		stored.CoverArtURL = ""
+		stored.CoverArt = coverArt
+	case override.CoverArtURL != "":
+		coverArt, err := s.MetaService.GetCoverArt(ctx, override.CoverArtURL)
+		if err != nil {
+			return fmt.Errorf("%w: cover art: %v", ErrInvalidOverride, err)
+		}
+		stored.CoverArtURL = override.CoverArtURL
+		stored.CoverArt = coverArt
+	}
+	if err := s.TrackSQL.PutOverride(ctx, stored); err != nil {
+		zaplog.ErrorC(ctx, "failed to store override", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	return s.applyOverrideToFile(ctx, id, stored)
+}
+
+// applyOverrideToFile rewrites the saved file of a track with override,
+// renaming it if the artist or title changed. Tracks that have not been
+// saved yet get the override when they are.
+func (s *Service) applyOverrideToFile(ctx context.Context, id string, override track_sql.Override) error {
+	track, err := s.TrackSQL.GetTrack(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get track", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	if track.Done != 1 || track.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(track.Path)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to read saved file", zap.String("id", id), zap.String("path", track.Path), zap.Error(err))
+		return err
+	}
+	outputData, tagged, err := s.MetaService.ApplyOverride(ctx, data, overrideMeta(override))
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to apply override", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	path, err := s.saveFile(ctx, outputData, tagged, track)
+	if errors.Is(err, ErrPathTaken) {
+		return fmt.Errorf("%w: %v", ErrInvalidOverride, err)
+	}
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to save file", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	track.Path = path
+	track.ContentHash = contentHash(outputData)
+	track.Artist = tagged.Artist
+	track.Album = tagged.Album
+	track.Genre = tagged.Genre
+	return s.TrackSQL.UpdateTrack(ctx, track)
+}
+
+// applyOverride returns trackMeta with the stored override for id applied,
+// and whether there was one.
+func (s *Service) applyOverride(ctx context.Context, id string, trackMeta meta.TrackMeta) (meta.TrackMeta, bool) {
+	override, err := s.TrackSQL.GetOverride(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			zaplog.ErrorC(ctx, "failed to get override", zap.String("id", id), zap.Error(err))
+		}
+		return trackMeta, false
+	}
+	zaplog.InfoC(ctx, "applying metadata override", zap.String("id", id))
+	return trackMeta.WithOverrides(overrideMeta(override)), true
+}
+
+func overrideMeta(override track_sql.Override) meta.TrackMeta {
+	return meta.TrackMeta{
+		Title:       override.Title,
+		Artist:      override.Artist,
+		Album:       override.Album,
+		Genre:       override.Genre,
+		Year:        override.Year,
+		CoverArtURL: override.CoverArtURL,
+		CoverArt:    override.CoverArt,
+	}
+}
@@ -0,0 +1,215 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+	"go.uber.org/zap"
+)
+
+// defaultPathTemplate is the flat layout used when no template is configured.
+const defaultPathTemplate = "{artist} - {title}.{ext}"
+
+// maxPathBytes is the longest path Linux accepts.
+const maxPathBytes = 4096
+
+const fileExtension = "mp3"
+
+var ErrInvalidPathTemplate = errors.New("invalid path template")
+
+var (
+	templateFieldRegex = regexp.MustCompile(`\{([a-z]+)(?::(\d+))?\}`)
+	templateGroupRegex = regexp.MustCompile(`\[([^\[\]]*)\]`)
+)
+
+// templateNumberFields are the fields that take a zero padding width, as in
+// {track:02}.
+var templateNumberFields = map[string]bool{"year": true, "track": true, "disc": true}
+
+var templateTextFields = map[string]bool{"title": true, "artist": true, "album": true, "albumartist": true, "genre": true, "version": true, "isrc": true, "id": true, "ext": true}
+
+// PathTemplate lays out a saved file relative to the download directory.
+// Fields are written {name} or, for numbers, {name:width} to zero pad them;
+// "/" starts a directory. Text in square brackets is left out when a field
+// inside it is empty, so "[{disc}-]{track:02}" renders "01" for a single
+// disc release. Other missing fields fall back to a placeholder such as
+// "Unknown Album".
+type PathTemplate struct {
+	Template string
+}
+
+// ParsePathTemplate checks that template only uses known fields and stays
+// inside the download directory.
+func ParsePathTemplate(template string) (PathTemplate, error) {
+	if strings.TrimSpace(template) == "" {
+		return PathTemplate{}, fmt.Errorf("%w: template is empty", ErrInvalidPathTemplate)
+	}
+	if strings.HasPrefix(template, "/") {
+		return PathTemplate{}, fmt.Errorf("%w: %q must be relative to the download directory", ErrInvalidPathTemplate, template)
+	}
+	for _, match := range templateFieldRegex.FindAllStringSubmatch(template, -1) {
+		field, width := match[1], match[2]
+		if !templateNumberFields[field] && !templateTextFields[field] {
+			return PathTemplate{}, fmt.Errorf("%w: unknown field %q", ErrInvalidPathTemplate, field)
+		}
+		if width != "" && !templateNumberFields[field] {
+			return PathTemplate{}, fmt.Errorf("%w: field %q does not take a width", ErrInvalidPathTemplate, field)
+		}
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if strings.Count(segment, "[") != strings.Count(segment, "]") {
+			return PathTemplate{}, fmt.Errorf("%w: unbalanced brackets in %q", ErrInvalidPathTemplate, segment)
+		}
+		if strings.Trim(templateFieldRegex.ReplaceAllString(segment, "x"), " .[]") == "" {
+			return PathTemplate{}, fmt.Errorf("%w: %q has an empty directory", ErrInvalidPathTemplate, template)
+		}
+	}
+	return PathTemplate{Template: template}, nil
+}
+
+// Render returns the sanitized path segments for a track. The last segment
+// is the file name.
+func (t PathTemplate) Render(trackMeta meta.TrackMeta, id string) []string {
+	values := templateValues(trackMeta, id)
+	rawSegments := strings.Split(t.Template, "/")
+	segments := make([]string, 0, len(rawSegments))
+	for i, rawSegment := range rawSegments {
+		segment := templateGroupRegex.ReplaceAllStringFunc(rawSegment, func(group string) string {
+			rendered, complete := renderFields(group[1:len(group)-1], values, false)
+			if !complete {
+				return ""
+			}
+			return rendered
+		})
+		segment, _ = renderFields(segment, values, true)
+		if i == len(rawSegments)-1 {
+			segments = append(segments, fileSegment(segment))
+		} else {
+			segments = append(segments, dirSegment(segment))
+		}
+	}
+	return segments
+}
+
+// renderFields substitutes the fields in str. Missing fields are replaced
+// with their placeholder when fallback is set and left empty otherwise;
+// complete reports whether every field had a value.
+func renderFields(str string, values map[string]string, fallback bool) (string, bool) {
+	complete := true
+	rendered := templateFieldRegex.ReplaceAllStringFunc(str, func(field string) string {
+		match := templateFieldRegex.FindStringSubmatch(field)
+		value := values[match[1]]
+		if value == "" {
+			complete = false
+			if fallback {
+				return templatePlaceholder(match[1])
+			}
+			return ""
+		}
+		if width, err := strconv.Atoi(match[2]); err == nil && len(value) < width {
+			value = strings.Repeat("0", width-len(value)) + value
+		}
+		return value
+	})
+	return rendered, complete
+}
+
+func templateValues(trackMeta meta.TrackMeta, id string) map[string]string {
+	number := func(n int) string {
+		if n <= 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	albumArtist := trackMeta.AlbumArtist
+	if albumArtist == "" {
+		albumArtist = trackMeta.Artist
+	}
+	return map[string]string{
+		"title":       trackMeta.Title,
+		"artist":      trackMeta.Artist,
+		"album":       trackMeta.Album,
+		"albumartist": albumArtist,
+		"genre":       trackMeta.Genre,
+		"version":     trackMeta.Version,
+		"isrc":        trackMeta.ISRC,
+		"id":          id,
+		"ext":         fileExtension,
+		"year":        number(trackMeta.Year),
+		"track":       number(trackMeta.TrackNumber),
+		"disc":        number(trackMeta.DiscNumber),
+	}
+}
+
+func templatePlaceholder(field string) string {
+	switch field {
+	case "title":
+		return "Untitled"
+	case "artist", "albumartist":
+		return "Unknown Artist"
+	case "album":
+		return "Unknown Album"
+	}
+	return ""
+}
+
+// dirSegment makes a rendered directory name safe to create.
+func dirSegment(str string) string {
+	name := sanitizeFilename(str, 0)
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// fileSegment makes a rendered file name safe to create, keeping its
+// extension when the name has to be shortened.
+func fileSegment(str string) string {
+	stem := strings.TrimSuffix(str, "."+fileExtension)
+	stem = sanitizeFilename(stem, len("."+fileExtension))
+	if stem == "" {
+		stem = "untitled"
+	}
+	return stem + "." + fileExtension
+}
+
+// pathTemplate returns the template a track is laid out with: the one it was
+// downloaded with, otherwise the configured template. A template that does
+// not parse is skipped with a warning.
+func (s *Service) pathTemplate(templateOverride string) PathTemplate {
+	for _, template := range []string{templateOverride, s.Config.Download.PathTemplate} {
+		if template == "" {
+			continue
+		}
+		parsed, err := ParsePathTemplate(template)
+		if err != nil {
+			zaplog.Warn("skipping path template", zap.String("template", template), zap.Error(err))
+			continue
+		}
+		return parsed
+	}
+	return PathTemplate{Template: defaultPathTemplate}
+}
+
+// trackPath renders the path a track is saved to, shortening the file name
+// when the whole path would be too long.
+func (s *Service) trackPath(trackMeta meta.TrackMeta, id string, templateOverride string) (string, error) {
+	segments := s.pathTemplate(templateOverride).Render(trackMeta, id)
+	path := filepath.Join(append([]string{s.Config.DownloadDir}, segments...)...)
+	if excess := len(path) - maxPathBytes; excess > 0 {
+		fileName := segments[len(segments)-1]
+		stem := strings.TrimSuffix(fileName, "."+fileExtension)
+		if excess >= len(stem) {
+			return "", fmt.Errorf("path for %s is longer than %d bytes", id, maxPathBytes)
+		}
+		segments[len(segments)-1] = fileSegment(truncateBytes(stem, len(stem)-excess))
+		path = filepath.Join(append([]string{s.Config.DownloadDir}, segments...)...)
+	}
+	return path, nil
+}
@@ -0,0 +1,93 @@
+package download
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+)
+
+func TestParsePathTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  error
+	}{
+		{"default", defaultPathTemplate, nil},
+		{"directories", "{albumartist}/{album} ({year})/[{disc}-]{track:02} {title}.{ext}", nil},
+		{"empty", " ", ErrInvalidPathTemplate},
+		{"absolute", "/music/{title}.{ext}", ErrInvalidPathTemplate},
+		{"unknown field", "{artist}/{mood}.{ext}", ErrInvalidPathTemplate},
+		{"width on text field", "{title:02}.{ext}", ErrInvalidPathTemplate},
+		{"unbalanced brackets", "[{disc}-{track}.{ext}", ErrInvalidPathTemplate},
+		{"empty directory", "{artist}//{title}.{ext}", ErrInvalidPathTemplate},
+		{"dot directory", "../{title}.{ext}", ErrInvalidPathTemplate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePathTemplate(tt.template)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePathTemplate(%q) error = %v, want %v", tt.template, err, tt.wantErr)
+			}
+			if err == nil && got.Template != tt.template {
+				t.Errorf("ParsePathTemplate(%q) = %q, want %q", tt.template, got.Template, tt.template)
+			}
+		})
+	}
+}
+
+func TestPathTemplateRender(t *testing.T) {
+	full := meta.TrackMeta{Title: "Song", Artist: "Artist", Album: "Album", Year: 2001, TrackNumber: 3, DiscNumber: 2}
+	tests := []struct {
+		name      string
+		template  string
+		trackMeta meta.TrackMeta
+		want      []string
+	}{
+		{"default", defaultPathTemplate, full, []string{"Artist - Song.mp3"}},
+		{"padded numbers", "{album}/{track:02} {title}.{ext}", full, []string{"Album", "03 Song.mp3"}},
+		{"optional group kept", "[{disc}-]{track:02}.{ext}", full, []string{"2-03.mp3"}},
+		{"optional group dropped", "[{disc}-]{track:02}.{ext}", meta.TrackMeta{TrackNumber: 3}, []string{"03.mp3"}},
+		{"placeholders", "{albumartist}/{album}/{title}.{ext}", meta.TrackMeta{}, []string{"Unknown Artist", "Unknown Album", "Untitled.mp3"}},
+		{"album artist falls back to artist", "{albumartist}/{title}.{ext}", full, []string{"Artist", "Song.mp3"}},
+		{"id", "{id}.{ext}", full, []string{"abc123.mp3"}},
+		{"separators sanitized", "{artist}/{title}.{ext}", meta.TrackMeta{Title: "A/B", Artist: "AC/DC"}, []string{"AC_DC", "A_B.mp3"}},
+		{"dot directory", "{album}/{title}.{ext}", meta.TrackMeta{Title: "Song", Album: ".."}, []string{"_", "Song.mp3"}},
+		{"empty file name", "[{year}].{ext}", meta.TrackMeta{}, []string{"untitled.mp3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (PathTemplate{Template: tt.template}).Render(tt.trackMeta, "abc123"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Render(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		reserve int
+		want    string
+	}{
+		{"reserved characters", `a\b/c:d*e?f"g<h>i|j`, 0, "a_b_c_d_e_f_g_h_i_j"},
+		{"control characters", "a\tb\x00c", 0, "a_b_c"},
+		{"bidi overrides", "a\u202eb\u2066c", 0, "a_b_c"},
+		{"trims dots and spaces", " . name . ", 0, "name"},
+		{"composes accents", "Beyoncé", 0, "Beyoncé"},
+		{"keeps unicode", "夜に駆ける", 0, "夜に駆ける"},
+		{"shortened to fit", strings.Repeat("a", 300), 4, strings.Repeat("a", maxFilenameBytes-4)},
+		{"shortened without splitting runes", strings.Repeat("é", 200), 0, strings.Repeat("é", maxFilenameBytes/2)},
+		{"no trailing dot after shortening", strings.Repeat("a", maxFilenameBytes-1) + ".b", 0, strings.Repeat("a", maxFilenameBytes-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.in, tt.reserve); got != tt.want {
+				t.Errorf("sanitizeFilename(%q, %d) = %q, want %q", tt.in, tt.reserve, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package download
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+const reconcileTarget = "library"
+
+var (
+	ErrNoReconcileReport = errors.New("the library has not been reconciled yet")
+	ErrReconcileBusy     = errors.New("too many reconciles are queued, try again later")
+)
+
+// StartReconcile queues a reconcile of the library outside the configured
+// interval. The returned job ID can be polled for progress. When the queue
+// is full the reconcile is refused rather than waiting for room.
+func (s *Service) StartReconcile(ctx context.Context) (string, error) {
+	jobID := s.Jobs.Create("reconcile", reconcileTarget)
+	select {
+	case s.ReconcileQueue <- jobID:
+		return jobID, nil
+	default:
+		s.Jobs.Finish(jobID, ErrReconcileBusy)
+		return "", ErrReconcileBusy
+	}
+}
+
+// GetReconcileReport returns the report of the last finished reconcile.
+func (s *Service) GetReconcileReport(ctx context.Context) (ReconcileReport, error) {
+	s.ReconcileLock.Lock()
+	defer s.ReconcileLock.Unlock()
+	if s.LastReconcile == nil {
+		return ReconcileReport{}, ErrNoReconcileReport
+	}
+	return *s.LastReconcile, nil
+}
+
+// ReconcileProcessor runs queued reconciles and, when an interval is
+// configured, reconciles the library that often.
+func (s *Service) ReconcileProcessor(ctx context.Context) {
+	interval := time.Duration(s.Config.Reconcile.Interval) * time.Minute
+	// Without an interval the tick channel stays nil and never fires.
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case jobID := <-s.ReconcileQueue:
+			s.processReconcile(ctx, jobID)
+			if ticker != nil {
+				ticker.Reset(interval)
+			}
+		case <-tick:
+			s.processReconcile(ctx, s.Jobs.Create("reconcile", reconcileTarget))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) processReconcile(ctx context.Context, jobID string) {
+	zaplog.InfoC(ctx, "processing reconcile", zap.String("jobID", jobID))
+	report, requeue, err := s.reconcileLibrary(ctx, jobID)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to reconcile library", zap.String("jobID", jobID), zap.Error(err))
+		s.Jobs.Finish(jobID, err)
+		return
+	}
+	// Downloads are queued once the save lock is released, as saving them
+	// takes it.
+	for _, request := range requeue {
+		s.DownloadQueue <- request
+		report.Requeued = append(report.Requeued, request.ID)
+	}
+	s.ReconcileLock.Lock()
+	s.LastReconcile = &report
+	s.ReconcileLock.Unlock()
+	s.Jobs.Finish(jobID, nil)
+	zaplog.InfoC(ctx, "finished reconcile", zap.String("jobID", jobID), zap.Int("missing", len(report.Missing)), zap.Int("renamed", len(report.Renamed)), zap.Int("modified", len(report.Modified)))
+}
+
+// errTrackChanged marks a track that was saved again while the library was
+// being scanned, so what the scan found no longer applies to it.
+var errTrackChanged = errors.New("track was saved again during the reconcile")
+
+// reconcileLibrary compares the saved tracks with the files in the download
+// directory. A track whose file is not at its path is looked for among the
+// files no track claims, first by content hash and then by the video ID it
+// was tagged with, and follows the file when found. Tracks that are not found
+// are marked as no longer saved and, when configured, returned to be
+// downloaded again. Files are read without holding the save lock; it is only
+// taken to check and record each track.
+func (s *Service) reconcileLibrary(ctx context.Context, jobID string) (ReconcileReport, []DownloadRequest, error) {
+	report := ReconcileReport{
+		JobID:     jobID,
+		StartedAt: time.Now(),
+		Modified:  make([]ReconciledFile, 0),
+		Renamed:   make([]ReconciledFile, 0),
+		Missing:   make([]ReconciledFile, 0),
+		Requeued:  make([]string, 0),
+		Untracked: make([]string, 0),
+	}
+	tracks, err := s.TrackSQL.ListTracks(ctx)
+	if err != nil {
+		return report, nil, err
+	}
+	files, err := s.libraryFiles()
+	if err != nil {
+		return report, nil, err
+	}
+	s.Jobs.SetTotal(jobID, len(tracks))
+	report.Scanned = len(files)
+	unclaimed := make(map[string]bool, len(files))
+	for _, path := range files {
+		unclaimed[path] = true
+	}
+	missing := make([]track_sql.Track, 0)
+	for _, track := range tracks {
+		if !unclaimed[track.Path] {
+			missing = append(missing, track)
+			continue
+		}
+		delete(unclaimed, track.Path)
+		s.finishReconcile(ctx, jobID, track, s.reconcileFile(ctx, &report, track))
+	}
+	byHash, byID := s.identifyFiles(ctx, unclaimed, len(missing) > 0)
+	requeue := make([]DownloadRequest, 0)
+	for _, track := range missing {
+		path, found := byHash[track.ContentHash]
+		if !found {
+			path, found = byID[track.ID]
+		}
+		switch {
+		case found && unclaimed[path]:
+			delete(unclaimed, path)
+			err = s.reconcileRename(ctx, &report, track, path)
+		case track.Path == "":
+			s.Jobs.Skip(jobID, track.ID, "file path was not recorded")
+			continue
+		default:
+			err = s.reconcileMissing(ctx, &report, track)
+			if err == nil && s.Config.Reconcile.Requeue {
+				requeue = append(requeue, DownloadRequest{ID: track.ID, Options: DownloadOptions{PreferATV: s.Config.Download.PreferATV, PathTemplate: track.PathTemplate}})
+			}
+		}
+		s.finishReconcile(ctx, jobID, track, err)
+	}
+	for _, path := range files {
+		if unclaimed[path] {
+			report.Untracked = append(report.Untracked, path)
+		}
+	}
+	report.FinishedAt = time.Now()
+	return report, requeue, nil
+}
+
+// finishReconcile records the outcome of reconciling a track on the job.
+func (s *Service) finishReconcile(ctx context.Context, jobID string, track track_sql.Track, err error) {
+	switch {
+	case errors.Is(err, errTrackChanged):
+		s.Jobs.Skip(jobID, track.ID, err.Error())
+	case err != nil:
+		zaplog.ErrorC(ctx, "failed to reconcile track", zap.String("id", track.ID), zap.Error(err))
+		s.Jobs.Fail(jobID, track.ID, err)
+	default:
+		s.Jobs.Complete(jobID, track.ID)
+	}
+}
+
+// updateScannedTrack takes the save lock and applies update to the track as
+// it is now. A track saved to another path since it was scanned is left
+// alone and errTrackChanged returned, as is one update finds changed.
+func (s *Service) updateScannedTrack(ctx context.Context, scanned track_sql.Track, update func(track *track_sql.Track) error) error {
+	s.SaveLock.Lock()
+	defer s.SaveLock.Unlock()
+	track, err := s.TrackSQL.GetTrack(ctx, scanned.ID)
+	if err != nil {
+		return err
+	}
+	if track.Path != scanned.Path {
+		return errTrackChanged
+	}
+	if err := update(&track); err != nil {
+		return err
+	}
+	return s.TrackSQL.UpdateTrack(ctx, track)
+}
+
+// reconcileFile checks that a track's file still has the contents it was
+// saved with. A changed file, such as one retagged by another program, is
+// reported and its new hash recorded.
+func (s *Service) reconcileFile(ctx context.Context, report *ReconcileReport, track track_sql.Track) error {
+	data, err := os.ReadFile(track.Path)
+	if err != nil {
+		return err
+	}
+	hash := contentHash(data)
+	if hash == track.ContentHash {
+		report.Unchanged++
+		return nil
+	}
+	err = s.updateScannedTrack(ctx, track, func(current *track_sql.Track) error {
+		// The file was rewritten by a save since it was read.
+		if current.ContentHash != track.ContentHash {
+			return errTrackChanged
+		}
+		current.ContentHash = hash
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Tracks saved before hashes were recorded only get one.
+	if track.ContentHash != "" {
+		zaplog.InfoC(ctx, "library file was modified", zap.String("id", track.ID), zap.String("path", track.Path))
+		report.Modified = append(report.Modified, ReconciledFile{ID: track.ID, Path: track.Path})
+	} else {
+		report.Unchanged++
+	}
+	return nil
+}
+
+func (s *Service) reconcileRename(ctx context.Context, report *ReconcileReport, track track_sql.Track, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	err = s.updateScannedTrack(ctx, track, func(current *track_sql.Track) error {
+		// Another track may have been saved to the path since the scan.
+		_, err := s.TrackSQL.GetTrackByPath(ctx, path)
+		if err == nil {
+			return errTrackChanged
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		current.Path = path
+		current.ContentHash = contentHash(data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	zaplog.InfoC(ctx, "library file was moved", zap.String("id", track.ID), zap.String("from", track.Path), zap.String("to", path))
+	report.Renamed = append(report.Renamed, ReconciledFile{ID: track.ID, Path: path, PreviousPath: track.Path})
+	return nil
+}
+
+func (s *Service) reconcileMissing(ctx context.Context, report *ReconcileReport, track track_sql.Track) error {
+	err := s.updateScannedTrack(ctx, track, func(current *track_sql.Track) error {
+		// The file may have been saved again since the scan.
+		if _, err := os.Stat(current.Path); err == nil {
+			return errTrackChanged
+		}
+		current.Done = 0
+		current.Error = 1
+		current.ErrorMessage = fmt.Sprintf("file missing from the library: %s", current.Path)
+		current.Path = ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	zaplog.WarnC(ctx, "library file is missing", zap.String("id", track.ID), zap.String("path", track.Path))
+	report.Missing = append(report.Missing, ReconciledFile{ID: track.ID, PreviousPath: track.Path})
+	return nil
+}
+
+// identifyFiles indexes the files no track claims by content hash and by the
+// video ID they were tagged with. Reading them is skipped when no track is
+// missing its file.
+func (s *Service) identifyFiles(ctx context.Context, paths map[string]bool, needed bool) (map[string]string, map[string]string) {
+	byHash := make(map[string]string)
+	byID := make(map[string]string)
+	if !needed {
+		return byHash, byID
+	}
+	for path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			zaplog.WarnC(ctx, "failed to read library file", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		byHash[contentHash(data)] = path
+		if tagged, err := meta.ReadTaggedTrack(data); err == nil && tagged.Provenance.VideoID != "" {
+			byID[tagged.Provenance.VideoID] = path
+		}
+	}
+	return byHash, byID
+}
@@ -0,0 +1,152 @@
+package download
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/jobs"
+	"go.uber.org/zap"
+)
+
+// RetagAll is the retag target that covers every track in the library.
+const RetagAll = "all"
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// errRetagSkipped wraps the reasons a track was left as it is.
+	errRetagSkipped = errors.New("skipped")
+)
+
+// StartRetag queues a job that re-resolves the metadata of already downloaded
+// tracks and rewrites their tags in place. target is a track ID, a playlist
+// ID or RetagAll. The returned job ID can be polled for progress.
+func (s *Service) StartRetag(ctx context.Context, target string) (string, error) {
+	jobID := s.Jobs.Create("retag", target)
+	s.RetagQueue <- RetagRequest{JobID: jobID, Target: target}
+	return jobID, nil
+}
+
+func (s *Service) RetagProcessor(ctx context.Context) {
+	for {
+		select {
+		case request := <-s.RetagQueue:
+			s.processRetag(ctx, request)
+		default:
+			time.Sleep(1 * time.Second)
+		}
+	}
+}
+
+func (s *Service) processRetag(ctx context.Context, request RetagRequest) {
+	zaplog.InfoC(ctx, "processing retag", zap.String("jobID", request.JobID), zap.String("target", request.Target))
+	ids, err := s.retagTargets(ctx, request.Target)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to list retag targets", zap.String("target", request.Target), zap.Error(err))
+		s.Jobs.Finish(request.JobID, err)
+		return
+	}
+	s.Jobs.SetTotal(request.JobID, len(ids))
+	for _, id := range ids {
+		err := s.retagTrack(ctx, id)
+		switch {
+		case errors.Is(err, errRetagSkipped):
+			zaplog.InfoC(ctx, "skipped retag", zap.String("id", id), zap.Error(err))
+			s.Jobs.Skip(request.JobID, id, err.Error())
+		case err != nil:
+			zaplog.ErrorC(ctx, "failed to retag track", zap.String("id", id), zap.Error(err))
+			s.Jobs.Fail(request.JobID, id, err)
+		default:
+			s.Jobs.Complete(request.JobID, id)
+		}
+	}
+	s.Jobs.Finish(request.JobID, nil)
+	zaplog.InfoC(ctx, "finished retag", zap.String("jobID", request.JobID))
+}
+
+func (s *Service) retagTargets(ctx context.Context, target string) ([]string, error) {
+	switch {
+	case target == RetagAll:
+		tracks, err := s.TrackSQL.ListTracks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(tracks))
+		for _, track := range tracks {
+			ids = append(ids, track.ID)
+		}
+		return ids, nil
+	case s.IsTrackID(target):
+		return []string{target}, nil
+	}
+	return s.YoutubeService.GetPlaylistEntries(ctx, target)
+}
+
+// retagTrack rewrites the tags of a saved track with freshly resolved
+// metadata and renames the file if its artist or title changed. A match that
+// would not be saved for a new download, because it is below the match
+// threshold or needs review, is not applied.
+func (s *Service) retagTrack(ctx context.Context, id string) error {
+	track, err := s.TrackSQL.GetTrack(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: track is not in the library", errRetagSkipped)
+	}
+	if err != nil {
+		return err
+	}
+	if track.Done != 1 {
+		return fmt.Errorf("%w: track has not been saved", errRetagSkipped)
+	}
+	if track.Path == "" {
+		return fmt.Errorf("%w: file path was not recorded", errRetagSkipped)
+	}
+	data, err := os.ReadFile(track.Path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: file %s no longer exists", errRetagSkipped, track.Path)
+	}
+	if err != nil {
+		return err
+	}
+	resolution, err := s.MetaService.ResolveMeta(ctx, track)
+	if err != nil {
+		return err
+	}
+	best, overridden := s.applyOverride(ctx, id, resolution.Best)
+	if !overridden && (s.needsReview(best) || best.MatchScore < s.MetaService.MatchThreshold()) {
+		return fmt.Errorf("%w: new match scored %.3f, below the match threshold", errRetagSkipped, best.MatchScore)
+	}
+	outputData, err := s.MetaService.ApplyMeta(ctx, data, track, best)
+	if err != nil {
+		return err
+	}
+	path, err := s.saveFile(ctx, outputData, best, track)
+	if errors.Is(err, ErrPathTaken) {
+		return fmt.Errorf("%w: %v", errRetagSkipped, err)
+	}
+	if err != nil {
+		return err
+	}
+	track.Path = path
+	track.ContentHash = contentHash(outputData)
+	track.Artist = best.Artist
+	track.Album = best.Album
+	track.MatchScore = best.MatchScore
+	return s.TrackSQL.UpdateTrack(ctx, track)
+}
+
+// GetJob returns the progress of a job started by StartRetag.
+func (s *Service) GetJob(ctx context.Context, id string) (jobs.Job, error) {
+	job, ok := s.Jobs.Get(id)
+	if !ok {
+		return jobs.Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *Service) ListJobs(ctx context.Context) []jobs.Job {
+	return s.Jobs.List()
+}
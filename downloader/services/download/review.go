@@ -0,0 +1,170 @@
+package download
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/fsutil"
+	"github.com/gcottom/yt-dl-services/downloader/services/meta"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+const defaultReviewCandidates = 5
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrInvalidChoice  = errors.New("invalid review choice")
+)
+
+// needsReview reports whether a match is too weak to be written without a
+// person confirming it. Without a configured review threshold, only tracks
+// no candidate matched are reviewed.
+func (s *Service) needsReview(trackMeta meta.TrackMeta) bool {
+	threshold := s.Config.Review.Threshold
+	if threshold <= 0 {
+		threshold = s.MetaService.MatchThreshold()
+	}
+	return s.Config.Review.Enabled && trackMeta.MatchScore < threshold
+}
+
+// parkForReview keeps the untagged audio in the pending directory and
+// records the best candidates so a choice can be made later.
+func (s *Service) parkForReview(ctx context.Context, track track_sql.Track, data []byte, resolution meta.MetaResolution) error {
+	if err := s.savePendingFile(ctx, data, track.ID); err != nil {
+		return err
+	}
+	limit := s.Config.Review.Candidates
+	if limit <= 0 {
+		limit = defaultReviewCandidates
+	}
+	candidates := resolution.Candidates
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	encoded, err := json.Marshal(candidates)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to marshal review candidates", zap.String("id", track.ID), zap.Error(err))
+		return err
+	}
+	track.MatchScore = resolution.Best.MatchScore
+	if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
+		zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", track.ID), zap.Error(err))
+		return err
+	}
+	review := track_sql.Review{ID: track.ID, Title: resolution.YTMeta.Title, Artist: resolution.YTMeta.Artist, Candidates: string(encoded), CreatedAt: time.Now().Unix()}
+	if err := s.TrackSQL.InsertReview(ctx, review); err != nil {
+		zaplog.ErrorC(ctx, "failed to insert review into db", zap.String("id", track.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ListReviews returns the tracks waiting for a metadata choice, oldest first.
+func (s *Service) ListReviews(ctx context.Context) ([]Review, error) {
+	rows, err := s.TrackSQL.ListReviews(ctx)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to list reviews", zap.Error(err))
+		return nil, err
+	}
+	reviews := make([]Review, 0, len(rows))
+	for _, row := range rows {
+		review, err := decodeReview(row)
+		if err != nil {
+			zaplog.ErrorC(ctx, "failed to decode review", zap.String("id", row.ID), zap.Error(err))
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// ResolveReview tags and saves a parked track with the chosen candidate
+// and/or hand-entered fields, then removes it from the review queue. Reviews
+// are resolved one at a time, so a review answered twice is only saved once.
+func (s *Service) ResolveReview(ctx context.Context, id string, choice ReviewChoice) error {
+	s.ReviewLock.Lock()
+	defer s.ReviewLock.Unlock()
+	row, err := s.TrackSQL.GetReview(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrReviewNotFound
+	}
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get review", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	review, err := decodeReview(row)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to decode review", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	var chosen meta.TrackMeta
+	switch {
+	case choice.Candidate != nil:
+		if *choice.Candidate < 0 || *choice.Candidate >= len(review.Candidates) {
+			return fmt.Errorf("%w: candidate %d does not exist", ErrInvalidChoice, *choice.Candidate)
+		}
+		chosen = review.Candidates[*choice.Candidate].Meta
+	case choice.Meta == nil:
+		return fmt.Errorf("%w: a candidate or metadata is required", ErrInvalidChoice)
+	}
+	if choice.Meta != nil {
+		chosen = chosen.WithOverrides(*choice.Meta)
+	}
+	if chosen.Title == "" || chosen.Artist == "" {
+		return fmt.Errorf("%w: title and artist are required", ErrInvalidChoice)
+	}
+	// A person confirmed the match.
+	chosen.MatchScore = 1
+	chosen, _ = s.applyOverride(ctx, id, s.MetaService.CreditArtists(s.MetaService.ApplyVersion(review.Title, chosen)))
+
+	track, err := s.TrackSQL.GetTrack(ctx, id)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get track", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	pendingPath := s.pendingFilePath(id)
+	data, err := os.ReadFile(pendingPath)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to read pending file", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	if err := s.finalizeTrack(ctx, track, data, chosen); err != nil {
+		return err
+	}
+	if err := s.TrackSQL.DeleteReview(ctx, id); err != nil {
+		zaplog.ErrorC(ctx, "failed to delete review", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	if err := os.Remove(pendingPath); err != nil {
+		zaplog.ErrorC(ctx, "failed to remove pending file", zap.String("id", id), zap.Error(err))
+	}
+	return nil
+}
+
+func decodeReview(row track_sql.Review) (Review, error) {
+	review := Review{ID: row.ID, Title: row.Title, Artist: row.Artist, CreatedAt: time.Unix(row.CreatedAt, 0)}
+	if err := json.Unmarshal([]byte(row.Candidates), &review.Candidates); err != nil {
+		return Review{}, err
+	}
+	return review, nil
+}
+
+func (s *Service) pendingFilePath(id string) string {
+	return filepath.Join(s.Config.Review.PendingDir, id+".mp3")
+}
+
+func (s *Service) savePendingFile(ctx context.Context, data []byte, id string) error {
+	if err := fsutil.WriteFileAtomic(s.pendingFilePath(id), data); err != nil {
+		zaplog.ErrorC(ctx, "failed to write pending file", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
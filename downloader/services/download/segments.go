@@ -0,0 +1,71 @@
+package download
+
+import (
+	"context"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/services/segments"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+// maxSegmentDurationDifference is how many milliseconds the length of the
+// video a segment was submitted for may differ from the downloaded one.
+// Segments of a video that was edited since no longer line up with it.
+const maxSegmentDurationDifference = 2000
+
+// minCutDuration is the least audio, in milliseconds, cutting segments may
+// leave. Segments covering more than that are not cut, as the video is then
+// not music at all or they were submitted wrongly.
+const minCutDuration = 30000
+
+// trackCuts returns the segments to cut out of the audio of a track when
+// segment removal is enabled, in order and with overlapping ones merged.
+// Audio whose segments cannot be fetched is converted whole.
+func (s *Service) trackCuts(ctx context.Context, track track_sql.Track) []segments.Segment {
+	if !s.Config.Segments.Enabled {
+		return nil
+	}
+	found, err := s.Segments.GetSegments(ctx, track.SourceVideoID())
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to get segments, converting without cuts", zap.String("id", track.ID), zap.Error(err))
+		return nil
+	}
+	cuts := make([]segments.Segment, 0, len(found))
+	for _, segment := range found {
+		if segment.VideoDuration > 0 && track.Duration > 0 && abs(segment.VideoDuration-track.Duration) > maxSegmentDurationDifference {
+			zaplog.InfoC(ctx, "skipping segment submitted for a different video length", zap.String("id", track.ID), zap.Int("videoDuration", segment.VideoDuration), zap.Int("duration", track.Duration))
+			continue
+		}
+		cuts = append(cuts, segment)
+	}
+	cuts = segments.Merge(cuts)
+	if track.Duration > 0 && track.Duration-segments.Length(cuts) < minCutDuration {
+		zaplog.WarnC(ctx, "segments cover too much of the track, converting without cuts", zap.String("id", track.ID), zap.Int("cut", segments.Length(cuts)), zap.Int("duration", track.Duration))
+		return nil
+	}
+	return cuts
+}
+
+// cutTrack records the segments cut out of a track's audio and shortens its
+// duration to match the converted audio.
+func cutTrack(track track_sql.Track, cuts []segments.Segment) track_sql.Track {
+	if len(cuts) == 0 {
+		return track
+	}
+	track.Cuts = segments.Encode(cuts)
+	if track.Duration > 0 {
+		track.Duration -= segments.Length(cuts)
+	}
+	return track
+}
+
+// trackSegments returns the segments cut out of a track's audio.
+func trackSegments(ctx context.Context, track track_sql.Track) []segments.Segment {
+	cuts, err := segments.Decode(track.Cuts)
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to decode cuts", zap.String("id", track.ID), zap.Error(err))
+		return nil
+	}
+	return cuts
+}
@@ -3,24 +3,29 @@ package download
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gcottom/go-zaplog"
 	"github.com/gcottom/retry"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/fsutil"
 	"github.com/gcottom/yt-dl-services/downloader/services/meta"
 	"github.com/gcottom/yt-dl-services/downloader/track_sql"
 	"go.uber.org/zap"
+	"golang.org/x/text/unicode/norm"
 )
 
-func (s *Service) InitiateDownload(ctx context.Context, id string) error {
-	s.DownloadQueue <- id
+func (s *Service) InitiateDownload(ctx context.Context, request DownloadRequest) error {
+	s.DownloadQueue <- request
 	return nil
 }
 
@@ -35,8 +40,8 @@ func (s *Service) GetDownloadStatus(ctx context.Context, id string) (string, err
 func (s *Service) QueueProcessor(ctx context.Context) {
 	for {
 		select {
-		case id := <-s.DownloadQueue:
-			s.processDownload(ctx, id)
+		case request := <-s.DownloadQueue:
+			s.processDownload(ctx, request)
 		default:
 			time.Sleep(1 * time.Second)
 		}
@@ -50,27 +55,45 @@ func (s *Service) ReDriverProcessor(ctx context.Context) {
 		case "":
 			time.Sleep(10 * time.Second)
 		default:
-			s.processDownload(ctx, id)
+			s.processDownload(ctx, DownloadRequest{ID: id, Options: s.reDriveOptions(id)})
 			time.Sleep(30 * time.Second)
 		}
 	}
 }
 
-func (s *Service) processDownload(ctx context.Context, id string) {
+// reDrive queues a failed track for another attempt with the options it was
+// originally requested with.
+func (s *Service) reDrive(id string, options DownloadOptions) {
+	s.ReDriveOptions.Store(id, options)
+	s.ReDriver.Add(id)
+}
+
+func (s *Service) reDriveOptions(id string) DownloadOptions {
+	if options, ok := s.ReDriveOptions.Load(id); ok {
+		return options.(DownloadOptions)
+	}
+	return DownloadOptions{PreferATV: s.Config.Download.PreferATV}
+}
+
+func (s *Service) processDownload(ctx context.Context, request DownloadRequest) {
+	id := request.ID
 	zaplog.InfoC(ctx, "processing download", zap.String("id", id))
 	if s.IsTrackID(id) {
 		zaplog.InfoC(ctx, "given ID is a track ID", zap.String("id", id))
 		wg := new(sync.WaitGroup)
 		wg.Add(1)
 		s.DLConcurrencyLimiter.Acquire()
-		go s.processTrack(ctx, id, wg)
+		go s.processTrack(ctx, id, request.Options, wg)
 	} else {
 		zaplog.InfoC(ctx, "given ID is a playlist ID", zap.String("id", id))
-		go s.processPlaylist(ctx, id)
+		go s.processPlaylist(ctx, id, request.Options)
 	}
 }
 
-func (s *Service) processPlaylist(ctx context.Context, id string) {
+func (s *Service) processPlaylist(ctx context.Context, id string, options DownloadOptions) {
+	if options.PathTemplate == "" {
+		options.PathTemplate = s.Config.Download.PlaylistTemplates[id]
+	}
 	s.PlaylistStatus[id] = false
 	playlistEntries, err := s.YoutubeService.GetPlaylistEntries(ctx, id)
 	if err != nil {
@@ -84,23 +107,24 @@ func (s *Service) processPlaylist(ctx context.Context, id string) {
 		trackID := entry
 		wg.Add(1)
 		s.DLConcurrencyLimiter.Acquire()
-		go s.processTrack(ctx, trackID, wg)
+		go s.processTrack(ctx, trackID, options, wg)
 	}
 	wg.Wait()
 	s.PlaylistStatus[id] = true
 }
 
-func (s *Service) processTrack(ctx context.Context, id string, wg *sync.WaitGroup) error {
+func (s *Service) processTrack(ctx context.Context, id string, options DownloadOptions, wg *sync.WaitGroup) error {
 	zaplog.InfoC(ctx, "processing track", zap.String("id", id))
-	res, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, s.retrieveTrack, ctx, id)
+	res, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, s.retrieveTrack, ctx, id, options)
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to retrieve track", zap.String("id", id), zap.Error(err))
 		s.DLConcurrencyLimiter.Release()
 		wg.Done()
-		s.ReDriver.Add(id)
+		s.reDrive(id, options)
 		return err
 	}
 	track := res[0].(track_sql.Track)
+	track.PathTemplate = options.PathTemplate
 	s.DLConcurrencyLimiter.Release()
 	s.ConversionConcurrencyLimiter.Acquire()
 	track, err = s.convertTrack(ctx, track)
@@ -117,11 +141,12 @@ func (s *Service) processTrack(ctx context.Context, id string, wg *sync.WaitGrou
 		zaplog.ErrorC(ctx, "failed to get genre", zap.String("id", id), zap.Error(err))
 		s.GenreConcurrencyLimiter.Release()
 		wg.Done()
-		s.ReDriver.Add(id)
+		s.reDrive(id, options)
 		return err
 	}
 	track = res[0].(track_sql.Track)
 	s.GenreConcurrencyLimiter.Release()
+	track = s.fingerprintTrack(ctx, track, fmt.Sprintf("./data/%s.mp3", id))
 	convertedFile, err := os.Open(fmt.Sprintf("./data/%s.mp3", id))
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to open converted file", zap.String("id", id), zap.Error(err))
@@ -140,57 +165,111 @@ func (s *Service) processTrack(ctx context.Context, id string, wg *sync.WaitGrou
 		wg.Done()
 		return err
 	}
-	outputData, meta, err := s.MetaService.SaveMeta(ctx, convertedData, track)
+	resolution, err := s.MetaService.ResolveMeta(ctx, track)
 	if err != nil {
-		zaplog.ErrorC(ctx, "failed to save meta", zap.String("id", id), zap.Error(err))
+		zaplog.ErrorC(ctx, "failed to resolve meta", zap.String("id", id), zap.Error(err))
+		wg.Done()
+		return err
+	}
+	best, overridden := s.applyOverride(ctx, id, resolution.Best)
+	if !overridden && s.needsReview(best) {
+		zaplog.InfoC(ctx, "parking low confidence match for review", zap.String("id", id), zap.Float64("score", best.MatchScore))
+		if err := s.parkForReview(ctx, track, convertedData, resolution); err != nil {
+			zaplog.ErrorC(ctx, "failed to park track for review", zap.String("id", id), zap.Error(err))
+			wg.Done()
+			return err
+		}
+		wg.Done()
+		return nil
+	}
+	if err := s.finalizeTrack(ctx, track, convertedData, best); err != nil {
 		wg.Done()
 		return err
 	}
-	if err := s.saveFile(ctx, outputData, meta); err != nil {
-		zaplog.ErrorC(ctx, "failed to save file", zap.String("id", id), zap.Error(err))
+	wg.Done()
+	return nil
+}
+
+// finalizeTrack tags the converted audio with trackMeta and the lyrics of the
+// video's captions, saves it to the download directory and records the track
+// as done. A download of a recording that is already saved is handled by the
+// duplicate policy.
+func (s *Service) finalizeTrack(ctx context.Context, track track_sql.Track, data []byte, trackMeta meta.TrackMeta) error {
+	duplicate, isDuplicate := s.findDuplicate(ctx, track)
+	if isDuplicate && s.duplicatePolicy() == DuplicateSkip {
+		track.DuplicateOf = duplicate.ID
+		track.Error = 1
+		track.ErrorMessage = fmt.Sprintf("not saved, the same recording as %s is", duplicate.ID)
+		if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
+			zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", track.ID), zap.Error(err))
+		}
+		return nil
+	}
+	trackMeta = s.addLyrics(ctx, track, trackMeta)
+	outputData, err := s.MetaService.ApplyMeta(ctx, data, track, trackMeta)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to save meta", zap.String("id", track.ID), zap.Error(err))
+		return err
+	}
+	// A download of a track that was saved before replaces its file.
+	if saved, err := s.TrackSQL.GetTrack(ctx, track.ID); err == nil {
+		track.Path = saved.Path
+	}
+	replace := isDuplicate && s.duplicatePolicy() == DuplicateReplace
+	if replace && track.Path == "" {
+		// The download takes the place of the file it replaces.
+		track.Path = duplicate.Path
+	}
+	if isDuplicate && !replace {
+		track.DuplicateOf = duplicate.ID
+	}
+	path, err := s.saveFile(ctx, outputData, trackMeta, track)
+	if errors.Is(err, ErrPathTaken) {
+		// The collision policy kept the other file; the track is recorded
+		// as not saved so it shows up when the library is checked.
+		track.Error = 1
+		track.ErrorMessage = err.Error()
+		if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
+			zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", track.ID), zap.Error(err))
+		}
+		return nil
+	}
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to save file", zap.String("id", track.ID), zap.Error(err))
 		track.Error = 1
 		track.ErrorMessage = err.Error()
 		/*if err := s.TrackSQL.UpdateTrack(ctx, track); err != nil {
-			zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", id), zap.Error(err))
+			zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", track.ID), zap.Error(err))
 		}*/
-		wg.Done()
 		return err
 	}
 	track.Done = 1
-	/*if err := s.TrackSQL.UpdateTrack(ctx, track); err != nil {
-		zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", id), zap.Error(err))
-	}*/
-	wg.Done()
+	track.Path = path
+	track.ContentHash = contentHash(outputData)
+	track.Artist = trackMeta.Artist
+	track.Album = trackMeta.Album
+	track.MatchScore = trackMeta.MatchScore
+	if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
+		zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", track.ID), zap.Error(err))
+	}
+	if replace {
+		zaplog.InfoC(ctx, "replaced duplicate", zap.String("id", track.ID), zap.String("replaced", duplicate.ID))
+		if err := s.releaseDuplicate(ctx, duplicate, track.ID, path); err != nil {
+			zaplog.ErrorC(ctx, "failed to release replaced duplicate", zap.String("id", duplicate.ID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
-func (s *Service) retrieveTrack(ctx context.Context, id string) (track_sql.Track, error) {
-	var track track_sql.Track
-	var err error
-	var trackData []byte
-	track.ID = id
-
-	track.Title, track.Author, err = s.YoutubeService.GetVideoInfo(ctx, id, false)
+func (s *Service) retrieveTrack(ctx context.Context, id string, options DownloadOptions) (track_sql.Track, error) {
+	track, err := s.videoTrack(ctx, id, options)
 	if err != nil {
-		zaplog.ErrorC(ctx, "failed to get track info, attempting with embedded player", zap.String("id", id), zap.Error(err))
-		track.Title, track.Author, err = s.YoutubeService.GetVideoInfo(ctx, id, true)
-		if err != nil {
-			track.Error = 1
-			track.ErrorMessage = err.Error()
-			zaplog.ErrorC(ctx, "failed to get track info with embedded player", zap.String("id", id), zap.Error(err))
-			/*if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
-				zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", id), zap.Error(err))
-			}*/
-			return track, err
-		}
+		track.Error = 1
+		track.ErrorMessage = err.Error()
+		return track, err
 	}
-	/*
-		if err := s.TrackSQL.InsertTrack(ctx, track); err != nil {
-			zaplog.ErrorC(ctx, "failed to insert track into db", zap.String("id", id), zap.Error(err))
-			return track, err
-		}*/
 
-	trackData, err = s.YoutubeService.Download(ctx, id, false)
+	trackData, bitrate, err := s.YoutubeService.Download(ctx, track.SourceVideoID(), false)
 	if err != nil {
 		track.Error = 1
 		track.ErrorMessage = err.Error()
@@ -201,6 +280,7 @@ func (s *Service) retrieveTrack(ctx context.Context, id string) (track_sql.Track
 			}*/
 		return track, err
 	}
+	track.Bitrate = bitrate
 	if err = s.saveTempFile(ctx, trackData, id); err != nil {
 		track.Error = 1
 		track.ErrorMessage = err.Error()
@@ -216,8 +296,74 @@ func (s *Service) retrieveTrack(ctx context.Context, id string) (track_sql.Track
 	return track, nil
 }
 
+// videoTrack returns the track for id with the details of the video its audio
+// would be downloaded from, falling back to the embedded player when the
+// video info cannot be fetched.
+func (s *Service) videoTrack(ctx context.Context, id string, options DownloadOptions) (track_sql.Track, error) {
+	track := track_sql.Track{ID: id, SourceID: s.atvSourceID(ctx, id, options)}
+	videoInfo, err := s.YoutubeService.GetVideoInfo(ctx, track.SourceVideoID(), false)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get track info, attempting with embedded player", zap.String("id", id), zap.Error(err))
+		videoInfo, err = s.YoutubeService.GetVideoInfo(ctx, track.SourceVideoID(), true)
+		if err != nil {
+			zaplog.ErrorC(ctx, "failed to get track info with embedded player", zap.String("id", id), zap.Error(err))
+			return track, err
+		}
+	}
+	track.Title = videoInfo.Title
+	track.Author = videoInfo.Author
+	track.Description = videoInfo.Description
+	track.Duration = int(videoInfo.Duration.Milliseconds())
+	return track, nil
+}
+
+// atvSourceID returns the ID of the art track to download in place of the
+// music video id, or "" to download id itself.
+func (s *Service) atvSourceID(ctx context.Context, id string, options DownloadOptions) string {
+	if !options.PreferATV {
+		return ""
+	}
+	atvID, err := s.MetaService.FindATVVersion(ctx, id)
+	if err != nil {
+		zaplog.WarnC(ctx, "failed to look up atv version, using requested video", zap.String("id", id), zap.Error(err))
+		return ""
+	}
+	if atvID == "" || atvID == id {
+		return ""
+	}
+	zaplog.InfoC(ctx, "downloading atv version instead of music video", zap.String("id", id), zap.String("atvID", atvID))
+	return atvID
+}
+
+// ExplainTrack resolves the metadata a download of request would be tagged
+// with, without downloading the audio. A stored override is applied to the
+// final metadata as it would be on download.
+func (s *Service) ExplainTrack(ctx context.Context, request DownloadRequest) (Explanation, error) {
+	track, err := s.videoTrack(ctx, request.ID, request.Options)
+	if err != nil {
+		return Explanation{}, err
+	}
+	explanation, err := s.MetaService.ExplainMeta(ctx, track)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to explain meta", zap.String("id", request.ID), zap.Error(err))
+		return Explanation{}, err
+	}
+	final, overridden := s.applyOverride(ctx, request.ID, explanation.Final)
+	explanation.Final = final
+	return Explanation{MetaExplanation: explanation, SourceID: track.SourceVideoID(), Overridden: overridden, NeedsReview: !overridden && s.needsReview(final)}, nil
+}
+
+// PreviewRewrite runs the rewrite rules over a title and artist without
+// downloading anything.
+func (s *Service) PreviewRewrite(ctx context.Context, preview RewritePreview) (meta.RewriteResult, error) {
+	return s.MetaService.PreviewRewrite(preview.Title, preview.Artist, preview.Rules)
+}
+
+// convertTrack converts the downloaded audio to mp3 with the track's
+// segments cut out.
 func (s *Service) convertTrack(ctx context.Context, track track_sql.Track) (track_sql.Track, error) {
-	if err := s.Converter.Convert(ctx, track.ID); err != nil {
+	cuts := s.trackCuts(ctx, track)
+	if err := s.Converter.Convert(ctx, track.ID, cuts); err != nil {
 		track.Error = 1
 		track.ErrorMessage = err.Error()
 		zaplog.ErrorC(ctx, "failed to convert track", zap.String("id", track.ID), zap.Error(err))
@@ -226,7 +372,7 @@ func (s *Service) convertTrack(ctx context.Context, track track_sql.Track) (trac
 		}*/
 		return track, err
 	}
-	return track, nil
+	return cutTrack(track, cuts), nil
 }
 
 func (s *Service) getGenre(ctx context.Context, track track_sql.Track) (track_sql.Track, error) {
@@ -269,31 +415,42 @@ func (s *Service) getGenre(ctx context.Context, track track_sql.Track) (track_sq
 	return track, nil
 }
 
-func (s *Service) saveFile(ctx context.Context, data []byte, meta meta.TrackMeta) error {
-	_, err := os.Stat(s.Config.DownloadDir)
+// saveFile writes data to the path the track's template renders for
+// trackMeta, creating directories as needed. When another track's file is
+// already there the configured collision policy decides what happens. The
+// track's previous file is removed once the new one is written, so a renamed
+// track does not leave a copy behind.
+func (s *Service) saveFile(ctx context.Context, data []byte, trackMeta meta.TrackMeta, track track_sql.Track) (string, error) {
+	path, err := s.trackPath(trackMeta, track.ID, track.PathTemplate)
 	if err != nil {
-		if os.IsNotExist(err) {
-			if err := os.Mkdir(s.Config.DownloadDir, 0755); err != nil {
-				zaplog.ErrorC(ctx, "failed to create download directory", zap.String("directory", s.Config.DownloadDir), zap.Error(err))
-				return err
-			}
-		} else {
-			zaplog.ErrorC(ctx, "failed to get download directory info", zap.String("directory", s.Config.DownloadDir), zap.Error(err))
-			return err
-		}
+		zaplog.ErrorC(ctx, "failed to render path", zap.String("id", track.ID), zap.Error(err))
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		zaplog.ErrorC(ctx, "failed to create directory", zap.String("directory", filepath.Dir(path)), zap.Error(err))
+		return "", err
 	}
-	fileName := s.sanitizeFilename(fmt.Sprintf("%s - %s", meta.Artist, meta.Title))
-	outputFile, err := os.Create(fmt.Sprintf("%s/%s.mp3", s.Config.DownloadDir, fileName))
+	// Claiming a path and writing to it happen together, so two tracks
+	// cannot both find the same path free.
+	s.SaveLock.Lock()
+	defer s.SaveLock.Unlock()
+	path, err = s.claimPath(ctx, path, track)
 	if err != nil {
-		zaplog.ErrorC(ctx, "failed to create file", zap.String("filename", fileName), zap.Error(err))
-		return err
+		zaplog.WarnC(ctx, "not saving file", zap.String("id", track.ID), zap.Error(err))
+		return "", err
 	}
-	defer outputFile.Close()
-	if _, err := outputFile.Write(data); err != nil {
-		zaplog.ErrorC(ctx, "failed to write to file", zap.String("filename", fileName), zap.Error(err))
-		return err
+	if err := fsutil.WriteFileAtomic(path, data); err != nil {
+		zaplog.ErrorC(ctx, "failed to write file", zap.String("path", path), zap.Error(err))
+		return "", err
 	}
-	return nil
+	if track.Path != "" && track.Path != path {
+		zaplog.InfoC(ctx, "renamed file", zap.String("id", track.ID), zap.String("from", track.Path), zap.String("to", path))
+		if err := os.Remove(track.Path); err != nil && !os.IsNotExist(err) {
+			zaplog.ErrorC(ctx, "failed to remove previous file", zap.String("path", track.Path), zap.Error(err))
+		}
+	}
+	s.saveLyrics(ctx, path, track.Path, trackMeta)
+	return path, nil
 }
 
 func (s *Service) saveTempFile(ctx context.Context, data []byte, id string) error {
@@ -322,9 +479,24 @@ func (s *Service) saveTempFile(ctx context.Context, data []byte, id string) erro
 	return nil
 }
 
-func (s *Service) sanitizeFilename(str string) string {
-	regex := regexp.MustCompile(`[\\/:*?"<>|\x00-\x1F]`)
-	safeStr := regex.ReplaceAllString(str, "_")
-	return strings.Trim(safeStr, " .")
+// sanitizeFilename replaces the characters filesystems reject and shortens
+// str so that reserve more bytes still fit in a file name.
+func sanitizeFilename(str string, reserve int) string {
+	regex := regexp.MustCompile(`[\\/:*?"<>|\p{Cc}\x{202A}-\x{202E}\x{2066}-\x{2069}]`)
+	safeStr := regex.ReplaceAllString(norm.NFC.String(str), "_")
+	safeStr = strings.Trim(safeStr, " .")
+	return strings.TrimRight(truncateBytes(safeStr, maxFilenameBytes-reserve), " .")
+}
 
+// truncateBytes shortens str to at most n bytes without splitting a UTF-8
+// sequence, since filesystems limit name length in bytes rather than
+// characters.
+func truncateBytes(str string, n int) string {
+	if len(str) <= n {
+		return str
+	}
+	for n > 0 && !utf8.RuneStart(str[n]) {
+		n--
+	}
+	return str[:n]
 }
@@ -0,0 +1,28 @@
+package download
+
+import "testing"
+
+func TestTruncateBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{"short enough", "abc", 5, "abc"},
+		{"exact", "abc", 3, "abc"},
+		{"ascii", "abcdef", 4, "abcd"},
+		{"two byte rune kept whole", "aéb", 3, "aé"},
+		{"two byte rune not split", "aéb", 2, "a"},
+		{"three byte rune not split", "夜に", 4, "夜"},
+		{"four byte rune not split", "🎵🎵", 7, "🎵"},
+		{"zero", "abc", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateBytes(tt.in, tt.n); got != tt.want {
+				t.Errorf("truncateBytes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+		})
+	}
+}
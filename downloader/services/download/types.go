@@ -2,21 +2,141 @@ package download
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/gcottom/semaphore"
 	"github.com/gcottom/yt-dl-services/downloader/config"
 	"github.com/gcottom/yt-dl-services/downloader/pkg/http_client"
 	"github.com/gcottom/yt-dl-services/downloader/services/converter"
+	"github.com/gcottom/yt-dl-services/downloader/services/fingerprint"
+	"github.com/gcottom/yt-dl-services/downloader/services/jobs"
 	"github.com/gcottom/yt-dl-services/downloader/services/meta"
 	"github.com/gcottom/yt-dl-services/downloader/services/redriver"
+	"github.com/gcottom/yt-dl-services/downloader/services/segments"
 	"github.com/gcottom/yt-dl-services/downloader/services/youtube_v2"
 	"github.com/gcottom/yt-dl-services/downloader/track_sql"
-	spotifyauth "github.com/zmb3/spotify/v2/auth"
-	"golang.org/x/oauth2/clientcredentials"
 )
 
+// maxFilenameBytes is the longest file name, in bytes, that common
+// filesystems accept.
+const maxFilenameBytes = 255
+
 type DownloadService interface {
-	InitiateDownload(ctx context.Context, id string) error
+	InitiateDownload(ctx context.Context, request DownloadRequest) error
+	ListReviews(ctx context.Context) ([]Review, error)
+	ResolveReview(ctx context.Context, id string, choice ReviewChoice) error
+	ExplainTrack(ctx context.Context, request DownloadRequest) (Explanation, error)
+	StartRetag(ctx context.Context, target string) (string, error)
+	GetJob(ctx context.Context, id string) (jobs.Job, error)
+	ListJobs(ctx context.Context) []jobs.Job
+	PreviewRewrite(ctx context.Context, preview RewritePreview) (meta.RewriteResult, error)
+	SetOverride(ctx context.Context, id string, override MetaOverride) error
+	RebuildLibrary(ctx context.Context) (RebuildReport, error)
+	StartReconcile(ctx context.Context) (string, error)
+	GetReconcileReport(ctx context.Context) (ReconcileReport, error)
+	ListDuplicates(ctx context.Context) ([]DuplicateGroup, error)
+}
+
+type DownloadRequest struct {
+	ID      string
+	Options DownloadOptions
+}
+
+type DownloadOptions struct {
+	// PreferATV downloads the art track of a song instead of its music video
+	// when YouTube Music has one.
+	PreferATV bool
+	// PathTemplate lays the saved files out instead of the configured
+	// template. See PathTemplate for the syntax.
+	PathTemplate string
+}
+
+// MetaOverride is metadata set by hand for a track. Empty fields are left to
+// the matcher.
+type MetaOverride struct {
+	Title       string `json:"title" form:"title"`
+	Artist      string `json:"artist" form:"artist"`
+	Album       string `json:"album" form:"album"`
+	Genre       string `json:"genre" form:"genre"`
+	Year        int    `json:"year" form:"year"`
+	CoverArtURL string `json:"coverArtURL" form:"coverArtURL"`
+	CoverArt    []byte `json:"-" form:"-"` // uploaded image, any supported format
+}
+
+// RewritePreview is a title and artist to run the rewrite rules over. Rules,
+// when given, are used instead of the configured ones.
+type RewritePreview struct {
+	Title  string               `json:"title"`
+	Artist string               `json:"artist"`
+	Rules  []config.RewriteRule `json:"rules"`
+}
+
+// RetagRequest is a queued retag job.
+type RetagRequest struct {
+	JobID  string
+	Target string
+}
+
+// ReconcileReport is what a reconcile found when it compared the saved tracks
+// with the files in the download directory.
+type ReconcileReport struct {
+	JobID      string           `json:"jobID"`
+	StartedAt  time.Time        `json:"startedAt"`
+	FinishedAt time.Time        `json:"finishedAt"`
+	Scanned    int              `json:"scanned"`   // files in the download directory
+	Unchanged  int              `json:"unchanged"` // tracks whose file is as it was saved
+	Modified   []ReconciledFile `json:"modified"`  // files changed since they were saved
+	Renamed    []ReconciledFile `json:"renamed"`   // files found at a new path
+	Missing    []ReconciledFile `json:"missing"`   // tracks whose file was not found
+	Requeued   []string         `json:"requeued"`  // missing tracks queued for download
+	Untracked  []string         `json:"untracked"` // files no track claims
+}
+
+// ReconciledFile is a track whose file was not as it was saved.
+type ReconciledFile struct {
+	ID           string `json:"id"`
+	Path         string `json:"path,omitempty"`
+	PreviousPath string `json:"previousPath,omitempty"`
+}
+
+// DuplicateGroup is a track and the downloads found to be the same
+// recording.
+type DuplicateGroup struct {
+	Original   DuplicateTrack   `json:"original"`
+	Duplicates []DuplicateTrack `json:"duplicates"`
+}
+
+type DuplicateTrack struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Path   string `json:"path"`
+	Saved  bool   `json:"saved"` // the track's file is in the library
+}
+
+// Explanation is a dry run of the metadata a track would be downloaded with.
+type Explanation struct {
+	meta.MetaExplanation
+	SourceID    string `json:"sourceID"`    // the video the audio would come from
+	Overridden  bool   `json:"overridden"`  // a stored override was applied to Final
+	NeedsReview bool   `json:"needsReview"` // the match would be parked for review
+}
+
+// Review is a downloaded track waiting for someone to choose its metadata.
+type Review struct {
+	ID         string                `json:"id"`
+	Title      string                `json:"title"`
+	Artist     string                `json:"artist"`
+	Candidates []meta.CandidateScore `json:"candidates"`
+	CreatedAt  time.Time             `json:"createdAt"`
+}
+
+// ReviewChoice picks one of a review's candidates, supplies the metadata by
+// hand, or both, in which case the hand-entered fields win.
+type ReviewChoice struct {
+	Candidate *int            `json:"candidate"`
+	Meta      *meta.TrackMeta `json:"meta"`
 }
 
 func NewDownloadService(cfg *config.Config, httpClient *http_client.HTTPClient, trackSQL *track_sql.Client) *Service {
@@ -24,8 +144,13 @@ func NewDownloadService(cfg *config.Config, httpClient *http_client.HTTPClient,
 		Config:                       cfg,
 		HTTPClient:                   httpClient,
 		Converter:                    &converter.Service{Config: cfg},
-		MetaService:                  &meta.Service{Config: cfg, HTTPClient: httpClient, SpotifyConfig: &clientcredentials.Config{ClientID: cfg.Spotify.ClientID, ClientSecret: cfg.Spotify.ClientSecret, TokenURL: spotifyauth.TokenURL}},
-		DownloadQueue:                make(chan string, 100),
+		Fingerprints:                 &fingerprint.Service{Config: cfg},
+		Segments:                     &segments.Service{Config: cfg, HTTPClient: httpClient},
+		MetaService:                  meta.NewMetaService(cfg, httpClient, trackSQL),
+		DownloadQueue:                make(chan DownloadRequest, 100),
+		RetagQueue:                   make(chan RetagRequest, 100),
+		ReconcileQueue:               make(chan string, 10),
+		Jobs:                         jobs.NewService(),
 		YoutubeService:               youtube_v2.NewYoutubeService(cfg, httpClient),
 		TrackSQL:                     trackSQL,
 		DLConcurrencyLimiter:         semaphore.NewSemaphore(cfg.Concurrency.Download),
@@ -40,8 +165,13 @@ type Service struct {
 	Config                       *config.Config
 	HTTPClient                   *http_client.HTTPClient
 	Converter                    converter.ConverterService
+	Fingerprints                 fingerprint.FingerprintService
+	Segments                     segments.SegmentService
 	MetaService                  meta.MetaService
-	DownloadQueue                chan string
+	DownloadQueue                chan DownloadRequest
+	RetagQueue                   chan RetagRequest
+	ReconcileQueue               chan string
+	Jobs                         jobs.JobService
 	YoutubeService               youtube_v2.YoutubeService
 	TrackSQL                     *track_sql.Client
 	DLConcurrencyLimiter         *semaphore.Semaphore
@@ -49,6 +179,11 @@ type Service struct {
 	GenreConcurrencyLimiter      *semaphore.Semaphore
 	PlaylistStatus               map[string]bool
 	ReDriver                     redriver.ReDriverService
+	ReDriveOptions               sync.Map
+	SaveLock                     sync.Mutex
+	ReconcileLock                sync.Mutex
+	ReviewLock                   sync.Mutex
+	LastReconcile                *ReconcileReport
 }
 
 type GenreResponse struct {
@@ -0,0 +1,56 @@
+package fingerprint
+
+import "encoding/base64"
+
+// chromaprintAlgorithm is the algorithm fpcalc fingerprints with by default,
+// which is the one AcoustID expects.
+const chromaprintAlgorithm = 1
+
+// Compress encodes a raw fingerprint the way fpcalc prints it without -raw,
+// the form AcoustID lookups take. Each item is XORed with the one before and
+// the positions of its set bits are written as 3 bit gaps, with gaps of 7 or
+// more continued in a second array of 5 bit values.
+func Compress(raw []uint32) string {
+	normal := make([]uint32, 0, 8*len(raw))
+	exceptional := make([]uint32, 0)
+	var previous uint32
+	for _, item := range raw {
+		bits := item ^ previous
+		previous = item
+		lastBit := uint32(0)
+		for bit := uint32(1); bits != 0; bit++ {
+			if bits&1 == 1 {
+				if gap := bit - lastBit; gap >= 7 {
+					normal = append(normal, 7)
+					exceptional = append(exceptional, gap-7)
+				} else {
+					normal = append(normal, gap)
+				}
+				lastBit = bit
+			}
+			bits >>= 1
+		}
+		normal = append(normal, 0)
+	}
+	size := len(raw)
+	data := []byte{chromaprintAlgorithm, byte(size >> 16), byte(size >> 8), byte(size)}
+	data = append(data, packBits(normal, 3)...)
+	data = append(data, packBits(exceptional, 5)...)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// packBits writes the low width bits of each value, least significant bit
+// first.
+func packBits(values []uint32, width int) []byte {
+	packed := make([]byte, (len(values)*width+7)/8)
+	position := 0
+	for _, value := range values {
+		for i := 0; i < width; i++ {
+			if value&(1<<i) != 0 {
+				packed[position/8] |= 1 << (position % 8)
+			}
+			position++
+		}
+	}
+	return packed
+}
@@ -0,0 +1,46 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+// The vectors are those of Chromaprint's FingerprintCompressor tests, with
+// the first byte set to the algorithm fpcalc uses.
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []uint32
+		want []byte
+	}{
+		{"one item one bit", []uint32{1}, []byte{1, 0, 0, 1, 1}},
+		{"one item three bits", []uint32{7}, []byte{1, 0, 0, 1, 73, 0}},
+		{"one item exceptional bit", []uint32{1 << 6}, []byte{1, 0, 0, 1, 7, 0}},
+		{"one item exceptional bit 2", []uint32{1 << 8}, []byte{1, 0, 0, 1, 7, 2}},
+		{"two items", []uint32{1, 0}, []byte{1, 0, 0, 2, 65, 0}},
+		{"two items no change", []uint32{1, 1}, []byte{1, 0, 0, 2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := base64.RawURLEncoding.DecodeString(Compress(tt.raw))
+			if err != nil {
+				t.Fatalf("Compress(%v) is not URL-safe base64: %v", tt.raw, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Compress(%v) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressSize(t *testing.T) {
+	raw := make([]uint32, 0x010203)
+	got, err := base64.RawURLEncoding.DecodeString(Compress(raw))
+	if err != nil {
+		t.Fatalf("Compress is not URL-safe base64: %v", err)
+	}
+	if !bytes.Equal(got[:4], []byte{1, 1, 2, 3}) {
+		t.Errorf("Compress header = %v, want [1 1 2 3]", got[:4])
+	}
+}
@@ -0,0 +1,103 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os/exec"
+
+	"github.com/gcottom/go-zaplog"
+	"go.uber.org/zap"
+)
+
+// maxOffset is how far, in fingerprint items, two fingerprints are shifted
+// against each other to line them up. 120 items is about 15 seconds, enough
+// for the intro of a music video.
+const maxOffset = 120
+
+// minItems is the shortest fingerprint, about 10 seconds, that is compared.
+// Shorter ones match too much by chance.
+const minItems = 80
+
+var ErrInvalidFingerprint = errors.New("invalid fingerprint")
+
+// Calculate fingerprints the audio file at path with the configured fpcalc.
+func (s *Service) Calculate(ctx context.Context, path string) (Fingerprint, error) {
+	fpcalcPath := s.Config.Fingerprint.FPCalcPath
+	if fpcalcPath == "" {
+		fpcalcPath = "fpcalc"
+	}
+	cmd := exec.CommandContext(ctx, fpcalcPath, "-raw", "-json", path)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		zaplog.ErrorC(ctx, "fingerprint error", zap.String("path", path), zap.String("stderr", stderr.String()), zap.Error(err))
+		return Fingerprint{}, err
+	}
+	var fingerprint Fingerprint
+	if err := json.Unmarshal(output, &fingerprint); err != nil {
+		zaplog.ErrorC(ctx, "failed to parse fpcalc output", zap.String("path", path), zap.Error(err))
+		return Fingerprint{}, err
+	}
+	if len(fingerprint.Raw) == 0 {
+		return Fingerprint{}, fmt.Errorf("%w: fpcalc returned no fingerprint for %s", ErrInvalidFingerprint, path)
+	}
+	return fingerprint, nil
+}
+
+// Encode packs a raw fingerprint into a string for storage.
+func Encode(raw []uint32) string {
+	data := make([]byte, 4*len(raw))
+	for i, item := range raw {
+		binary.LittleEndian.PutUint32(data[4*i:], item)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Decode unpacks a fingerprint packed by Encode.
+func Decode(str string) ([]uint32, error) {
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFingerprint, err)
+	}
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("%w: %d bytes is not a whole number of items", ErrInvalidFingerprint, len(data))
+	}
+	raw := make([]uint32, len(data)/4)
+	for i := range raw {
+		raw[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return raw, nil
+}
+
+// Similarity returns the share of matching bits between two fingerprints at
+// the offset that lines them up best, from 0 to 1. Unrelated audio scores
+// around 0.5; two encodes of the same recording score close to 1. Offsets
+// that leave less than half of the shorter fingerprint overlapping are not
+// considered.
+func Similarity(a []uint32, b []uint32) float64 {
+	if min(len(a), len(b)) < minItems {
+		return 0
+	}
+	minOverlap := min(len(a), len(b)) / 2
+	best := 0.0
+	for offset := -maxOffset; offset <= maxOffset; offset++ {
+		start := max(0, -offset)
+		end := min(len(a), len(b)-offset)
+		if end-start < minOverlap {
+			continue
+		}
+		bitErrors := 0
+		for i := start; i < end; i++ {
+			bitErrors += bits.OnesCount32(a[i] ^ b[i+offset])
+		}
+		best = max(best, 1-float64(bitErrors)/float64(32*(end-start)))
+	}
+	return best
+}
@@ -0,0 +1,94 @@
+package fingerprint
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomFingerprint(seed int64, n int) []uint32 {
+	r := rand.New(rand.NewSource(seed))
+	raw := make([]uint32, n)
+	for i := range raw {
+		raw[i] = r.Uint32()
+	}
+	return raw
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []uint32
+	}{
+		{"empty", []uint32{}},
+		{"one item", []uint32{0xdeadbeef}},
+		{"extremes", []uint32{0, 1, 0xffffffff}},
+		{"fingerprint", randomFingerprint(1, 500)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(Encode(tt.raw))
+			if err != nil {
+				t.Fatalf("Decode(Encode(%v)) error = %v", tt.raw, err)
+			}
+			if !reflect.DeepEqual(got, tt.raw) {
+				t.Errorf("Decode(Encode(%v)) = %v", tt.raw, got)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+	}{
+		{"not base64", "not base64!"},
+		{"partial item", "AQID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.str); !errors.Is(err, ErrInvalidFingerprint) {
+				t.Errorf("Decode(%q) error = %v, want ErrInvalidFingerprint", tt.str, err)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	recording := randomFingerprint(1, 1000)
+	// Every item of the re-encode differs from the original in two bits.
+	reencoded := make([]uint32, len(recording))
+	for i, item := range recording {
+		reencoded[i] = item ^ 0x00010001
+	}
+	tests := []struct {
+		name string
+		a    []uint32
+		b    []uint32
+		min  float64
+		max  float64
+	}{
+		{"identical", recording, recording, 1, 1},
+		{"re-encoded", recording, reencoded, 0.93, 0.94},
+		{"longer intro", recording, append(randomFingerprint(2, 100), recording...), 1, 1},
+		{"cut intro", recording[100:], recording, 1, 1},
+		{"intro longer than the maximum offset", recording, append(randomFingerprint(2, maxOffset+1), recording...), 0.4, 0.6},
+		{"cut short", recording, recording[:600], 1, 1},
+		{"unrelated", recording, randomFingerprint(2, 1000), 0.4, 0.6},
+		{"too short", recording[:minItems-1], recording[:minItems-1], 0, 0},
+		{"empty", nil, recording, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Similarity() = %.3f, want between %.2f and %.2f", got, tt.min, tt.max)
+			}
+			if reverse := Similarity(tt.b, tt.a); reverse != got {
+				t.Errorf("Similarity is not symmetric: %.3f vs %.3f", got, reverse)
+			}
+		})
+	}
+}
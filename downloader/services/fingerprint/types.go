@@ -0,0 +1,22 @@
+package fingerprint
+
+import (
+	"context"
+
+	"github.com/gcottom/yt-dl-services/downloader/config"
+)
+
+type FingerprintService interface {
+	Calculate(ctx context.Context, path string) (Fingerprint, error)
+}
+
+type Service struct {
+	Config *config.Config
+}
+
+// Fingerprint is the raw Chromaprint fingerprint of an audio file. Each item
+// covers about 0.124 seconds of audio.
+type Fingerprint struct {
+	Duration float64  `json:"duration"` // seconds
+	Raw      []uint32 `json:"fingerprint"`
+}
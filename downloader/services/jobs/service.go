@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Create registers a running job and returns its ID.
+func (s *Service) Create(kind string, target string) string {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	id := uuid.NewString()
+	s.Jobs[id] = &Job{
+		ID:        id,
+		Kind:      kind,
+		Target:    target,
+		State:     StateRunning,
+		Notes:     make(map[string]string),
+		Errors:    make(map[string]string),
+		StartedAt: time.Now(),
+	}
+	return id
+}
+
+func (s *Service) SetTotal(id string, total int) {
+	s.update(id, func(job *Job) {
+		job.Total = total
+	})
+}
+
+func (s *Service) Complete(id string, item string) {
+	s.update(id, func(job *Job) {
+		job.Completed++
+	})
+}
+
+func (s *Service) Skip(id string, item string, reason string) {
+	s.update(id, func(job *Job) {
+		job.Skipped++
+		job.Notes[item] = reason
+	})
+}
+
+func (s *Service) Fail(id string, item string, err error) {
+	s.update(id, func(job *Job) {
+		job.Failed++
+		job.Errors[item] = err.Error()
+	})
+}
+
+// Finish marks the job as done, or as failed when err is not nil.
+func (s *Service) Finish(id string, err error) {
+	s.update(id, func(job *Job) {
+		now := time.Now()
+		job.FinishedAt = &now
+		job.State = StateDone
+		if err != nil {
+			job.State = StateFailed
+			job.Error = err.Error()
+		}
+	})
+}
+
+// Get returns a snapshot of the job.
+func (s *Service) Get(id string) (Job, bool) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	job, ok := s.Jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// List returns a snapshot of every job, newest first.
+func (s *Service) List() []Job {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	jobs := make([]Job, 0, len(s.Jobs))
+	for _, job := range s.Jobs {
+		jobs = append(jobs, job.snapshot())
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.After(jobs[j].StartedAt)
+	})
+	return jobs
+}
+
+func (s *Service) update(id string, fn func(job *Job)) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	if job, ok := s.Jobs[id]; ok {
+		fn(job)
+	}
+}
+
+func (j *Job) snapshot() Job {
+	snapshot := *j
+	snapshot.Notes = make(map[string]string, len(j.Notes))
+	for item, note := range j.Notes {
+		snapshot.Notes[item] = note
+	}
+	snapshot.Errors = make(map[string]string, len(j.Errors))
+	for item, err := range j.Errors {
+		snapshot.Errors[item] = err
+	}
+	return snapshot
+}
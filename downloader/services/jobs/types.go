@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+type JobService interface {
+	Create(kind string, target string) string
+	SetTotal(id string, total int)
+	Complete(id string, item string)
+	Skip(id string, item string, reason string)
+	Fail(id string, item string, err error)
+	Finish(id string, err error)
+	Get(id string) (Job, bool)
+	List() []Job
+}
+
+type Service struct {
+	Lock *sync.Mutex
+	Jobs map[string]*Job
+}
+
+func NewService() *Service {
+	return &Service{
+		Lock: new(sync.Mutex),
+		Jobs: make(map[string]*Job),
+	}
+}
+
+// Job is the progress of a long running operation over many tracks.
+type Job struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Target     string            `json:"target"`
+	State      string            `json:"state"`
+	Error      string            `json:"error,omitempty"`
+	Total      int               `json:"total"`
+	Completed  int               `json:"completed"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Notes      map[string]string `json:"notes"`  // why items were skipped, by item
+	Errors     map[string]string `json:"errors"` // why items failed, by item
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+}
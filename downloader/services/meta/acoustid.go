@@ -0,0 +1,141 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/retry"
+	"github.com/gcottom/yt-dl-services/downloader/services/fingerprint"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+const defaultAcoustIDMinScore = 0.8
+
+// AcoustIDProvider identifies a track by the audio fingerprint it was
+// downloaded with and returns the MusicBrainz recordings AcoustID maps it
+// to. Tracks without a fingerprint are not looked up. AcoustID allows three
+// requests a second.
+type AcoustIDProvider struct {
+	Service *Service
+	Limiter *RateLimiter
+}
+
+func (p *AcoustIDProvider) Name() string {
+	return "acoustid"
+}
+
+func (p *AcoustIDProvider) Search(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) ([]TrackMeta, error) {
+	if trackData.Fingerprint == "" {
+		return nil, nil
+	}
+	raw, err := fingerprint.Decode(trackData.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	res, err := retry.Retry(retry.NewAlgSimpleDefault(), 3, p.lookup, ctx, fingerprint.Compress(raw), trackData.Duration/1000)
+	if err != nil {
+		return nil, err
+	}
+	return res[0].([]TrackMeta), nil
+}
+
+func (p *AcoustIDProvider) lookup(ctx context.Context, compressed string, duration int) ([]TrackMeta, error) {
+	zaplog.InfoC(ctx, "looking up fingerprint with acoustid", zap.Int("duration", duration))
+	if err := p.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"client":      {p.Service.Config.AcoustID.APIKey},
+		"meta":        {"recordings releasegroups"},
+		"duration":    {fmt.Sprint(duration)},
+		"fingerprint": {compressed},
+	}
+	endpoint := fmt.Sprintf("%s/lookup?%s", strings.TrimRight(p.Service.Config.AcoustID.Endpoint, "/"), query.Encode())
+	req, err := p.Service.HTTPClient.CreateRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to create acoustid request", zap.Error(err))
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	res, status, err := p.Service.HTTPClient.DoRequest(req)
+	if err != nil {
+		zaplog.ErrorC(ctx, "error while sending acoustid request", zap.Error(err))
+		return nil, err
+	}
+	var response AcoustIDResponse
+	if err = json.Unmarshal(res, &response); err != nil {
+		zaplog.ErrorC(ctx, "failed to unmarshal acoustid response", zap.Int("status", status), zap.Error(err))
+		return nil, err
+	}
+	if status != http.StatusOK || response.Status != "ok" {
+		zaplog.ErrorC(ctx, "acoustid request failed", zap.Int("status", status), zap.String("error", response.Error.Message))
+		return nil, fmt.Errorf("acoustid request failed with status %d: %s", status, response.Error.Message)
+	}
+	trackMetas := make([]TrackMeta, 0)
+	for _, result := range response.Results {
+		for _, recording := range result.Recordings {
+			// Recordings AcoustID has no metadata for cannot be tagged.
+			if recording.Title == "" {
+				continue
+			}
+			trackMetas = append(trackMetas, acoustIDRecordingMeta(recording, result.Score))
+		}
+	}
+	return trackMetas, nil
+}
+
+func acoustIDRecordingMeta(recording AcoustIDRecording, score float64) TrackMeta {
+	// AcoustID credits artists the way MusicBrainz does, without nesting
+	// the artist.
+	credits := make(MusicBrainzArtistCredits, 0, len(recording.Artists))
+	for _, artist := range recording.Artists {
+		credit := MusicBrainzArtistCredit{Name: artist.Name, JoinPhrase: artist.JoinPhrase}
+		credit.Artist.ID = artist.ID
+		credit.Artist.Name = artist.Name
+		credits = append(credits, credit)
+	}
+	artists, artistIDs := credits.names()
+	mainArtists, featured := credits.split()
+	trackMeta := TrackMeta{
+		Title:                  recording.Title,
+		Artist:                 strings.Join(artists, ", "),
+		Artists:                mainArtists,
+		FeaturedArtists:        featured,
+		Duration:               int(recording.Duration * 1000),
+		MusicBrainzRecordingID: recording.ID,
+		MusicBrainzArtistID:    strings.Join(artistIDs, "/"),
+		FingerprintScore:       score,
+	}
+	if len(recording.ReleaseGroups) == 0 {
+		return trackMeta
+	}
+	// Prefer a studio album over singles and compilations.
+	releaseGroup := recording.ReleaseGroups[0]
+	for _, candidate := range recording.ReleaseGroups {
+		if candidate.Type == "Album" && len(candidate.SecondaryTypes) == 0 {
+			releaseGroup = candidate
+			break
+		}
+	}
+	trackMeta.Album = releaseGroup.Title
+	trackMeta.MusicBrainzReleaseGroupID = releaseGroup.ID
+	return trackMeta
+}
+
+// identifiedRecordings returns the MusicBrainz recordings the fingerprint
+// was matched to and the AcoustID score of each.
+func identifiedRecordings(ranked []CandidateScore) map[string]float64 {
+	recordings := make(map[string]float64)
+	for _, candidate := range ranked {
+		if candidate.Meta.FingerprintScore > 0 && candidate.Meta.MusicBrainzRecordingID != "" {
+			recordings[candidate.Meta.MusicBrainzRecordingID] = max(recordings[candidate.Meta.MusicBrainzRecordingID], candidate.Meta.FingerprintScore)
+		}
+	}
+	return recordings
+}
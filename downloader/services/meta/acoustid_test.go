@@ -0,0 +1,126 @@
+package meta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/http_client"
+)
+
+func TestAcoustIDLookup(t *testing.T) {
+	responses := map[string]struct {
+		code int
+		body string
+	}{
+		"match": {http.StatusOK, `{"status": "ok", "results": [
+			{"id": "a1", "score": 0.93, "recordings": [
+				{"id": "rec1", "title": "Song", "duration": 201.5,
+					"artists": [{"id": "art1", "name": "Alice", "joinphrase": " feat. "}, {"id": "art2", "name": "Bob"}],
+					"releasegroups": [
+						{"id": "rg1", "title": "Song (Single)", "type": "Single"},
+						{"id": "rg2", "title": "Live Album", "type": "Album", "secondarytypes": ["Live"]},
+						{"id": "rg3", "title": "Album", "type": "Album"}
+					]},
+				{"id": "rec2"}
+			]}
+		]}`},
+		"invalid key":  {http.StatusBadRequest, `{"status": "error", "error": {"code": 4, "message": "invalid API key"}}`},
+		"error status": {http.StatusOK, `{"status": "error", "error": {"code": 3, "message": "invalid fingerprint"}}`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lookup" {
+			t.Errorf("request path = %q, want /lookup", r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("duration") != "201" || query.Get("fingerprint") != "AQAAAQE" || query.Get("meta") != "recordings releasegroups" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		response := responses[query.Get("client")]
+		w.WriteHeader(response.code)
+		w.Write([]byte(response.body))
+	}))
+	defer server.Close()
+	ctx := zaplog.CreateAndInject(context.Background())
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		want    []TrackMeta
+		wantErr bool
+	}{
+		{"match", "match", []TrackMeta{{
+			Title:                     "Song",
+			Artist:                    "Alice, Bob",
+			Album:                     "Album",
+			Duration:                  201500,
+			MusicBrainzRecordingID:    "rec1",
+			MusicBrainzReleaseGroupID: "rg3",
+			MusicBrainzArtistID:       "art1/art2",
+			FingerprintScore:          0.93,
+		}}, false},
+		{"error response", "invalid key", nil, true},
+		{"status not ok", "error status", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.AcoustID.Endpoint = server.URL + "/"
+			cfg.AcoustID.APIKey = tt.apiKey
+			provider := &AcoustIDProvider{Service: &Service{Config: cfg, HTTPClient: http_client.NewHTTPClient()}, Limiter: NewRateLimiter(time.Millisecond)}
+			got, err := provider.lookup(ctx, "AQAAAQE", 201)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("lookup() returned %d recordings, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.Title != w.Title || g.Artist != w.Artist || g.Album != w.Album || g.Duration != w.Duration ||
+					g.MusicBrainzRecordingID != w.MusicBrainzRecordingID || g.MusicBrainzReleaseGroupID != w.MusicBrainzReleaseGroupID ||
+					g.MusicBrainzArtistID != w.MusicBrainzArtistID || g.FingerprintScore != w.FingerprintScore {
+					t.Errorf("lookup()[%d] = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestChooseCandidate(t *testing.T) {
+	candidate := func(score float64, recordingID string, fingerprintScore float64) CandidateScore {
+		return CandidateScore{Score: score, Meta: TrackMeta{Confidence: 1, MusicBrainzRecordingID: recordingID, FingerprintScore: fingerprintScore}}
+	}
+	rejected := candidate(0, "fp", 0.95)
+	rejected.Rejected = "live upload but studio candidate"
+	tests := []struct {
+		name          string
+		ranked        []CandidateScore
+		want          int
+		wantSelection string
+	}{
+		{"nothing ranked", nil, -1, ""},
+		{"best score without a fingerprint", []CandidateScore{candidate(0.9, "a", 0), candidate(0.85, "b", 0)}, 0, selectedByScore},
+		{"best score is the identified recording", []CandidateScore{candidate(0.9, "a", 0.9), candidate(0.88, "b", 0)}, 0, selectedByScore},
+		{"close runner-up is identified", []CandidateScore{candidate(0.9, "a", 0), candidate(0.88, "b", 0.9)}, 1, selectedByTieBreak},
+		{"runner-up shares an identified recording", []CandidateScore{candidate(0.9, "a", 0), candidate(0.87, "b", 0), candidate(0.5, "b", 0.9)}, 1, selectedByTieBreak},
+		{"identified runner-up too far behind", []CandidateScore{candidate(0.95, "a", 0), candidate(0.8, "b", 0.9)}, 0, selectedByScore},
+		{"identified runner-up below the threshold", []CandidateScore{candidate(0.9, "a", 0), candidate(0.6, "b", 0.9)}, 0, selectedByScore},
+		{"fingerprint fallback", []CandidateScore{candidate(0.5, "a", 0), candidate(0.3, "b", 0.85), candidate(0.2, "c", 0.9)}, 2, selectedByFingerprint},
+		{"fingerprint below the minimum", []CandidateScore{candidate(0.5, "a", 0), candidate(0.3, "b", 0.7)}, -1, ""},
+		{"rejected fingerprint match", []CandidateScore{candidate(0.5, "a", 0), rejected}, -1, ""},
+	}
+	s := &Service{Config: &config.Config{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, selection := s.chooseCandidate(tt.ranked)
+			if got != tt.want || selection != tt.wantSelection {
+				t.Errorf("chooseCandidate() = %d, %q, want %d, %q", got, selection, tt.want, tt.wantSelection)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package meta
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Where featured artists are credited once a track is resolved.
+const (
+	FeatPolicyTitle  = "title"  // "Title (feat. X)" by "Artist"
+	FeatPolicyArtist = "artist" // "Title" by "Artist feat. X"
+	FeatPolicyBoth   = "both"   // "Title (feat. X)" by "Artist feat. X"
+)
+
+var (
+	bracketedFeatRegex = regexp.MustCompile(`(?i)\s*[(\[]\s*(?:feat\.?|ft\.?|featuring)\s+([^()\[\]]+?)\s*[)\]]`)
+	trailingFeatRegex  = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring)\s+(.+)$`)
+	featNameSeparator  = regexp.MustCompile(`\s*(?:,|&|\band\b)\s*`)
+)
+
+// CreditArtists splits the featured artists out of the title and artist of
+// trackMeta and credits them again according to the configured policy. The
+// structured Artists and FeaturedArtists lists are filled in either way.
+// Crediting a track twice gives the same result.
+func (s *Service) CreditArtists(trackMeta TrackMeta) TrackMeta {
+	title, titleFeatured := splitFeatured(trackMeta.Title)
+	artist, artistFeatured := splitFeatured(trackMeta.Artist)
+	featured := uniqueNames(append(append(append([]string{}, trackMeta.FeaturedArtists...), titleFeatured...), artistFeatured...))
+	artists := trackMeta.Artists
+	if len(artists) == 0 && artist != "" {
+		artists = []string{artist}
+	}
+	// Providers list featured artists next to the main ones, so anyone the
+	// title names as featured is only credited as featured.
+	if primary := withoutNames(artists, featured); len(primary) > 0 {
+		artists = primary
+	}
+	trackMeta.Artists = artists
+	trackMeta.FeaturedArtists = featured
+	trackMeta.Title = title
+	trackMeta.Artist = strings.Join(artists, ", ")
+	if len(featured) == 0 {
+		return trackMeta
+	}
+	credit := joinNames(featured)
+	switch s.featPolicy() {
+	case FeatPolicyTitle:
+		trackMeta.Title += " (feat. " + credit + ")"
+	case FeatPolicyBoth:
+		trackMeta.Title += " (feat. " + credit + ")"
+		trackMeta.Artist += " feat. " + credit
+	default:
+		trackMeta.Artist += " feat. " + credit
+	}
+	return trackMeta
+}
+
+// performers returns the values written to the artist frame: the featured
+// artists are only included when the policy credits them as artists.
+func (s *Service) performers(trackMeta TrackMeta) []string {
+	if s.featPolicy() == FeatPolicyTitle {
+		return trackMeta.Artists
+	}
+	return append(append([]string{}, trackMeta.Artists...), trackMeta.FeaturedArtists...)
+}
+
+func (s *Service) featPolicy() string {
+	switch s.Config.Metadata.FeatPolicy {
+	case FeatPolicyTitle, FeatPolicyBoth:
+		return s.Config.Metadata.FeatPolicy
+	}
+	return FeatPolicyArtist
+}
+
+// splitFeatured removes a "feat." clause from str and returns the names it
+// listed.
+func splitFeatured(str string) (string, []string) {
+	featured := make([]string, 0)
+	for _, match := range bracketedFeatRegex.FindAllStringSubmatch(str, -1) {
+		featured = append(featured, featNameSeparator.Split(match[1], -1)...)
+	}
+	str = bracketedFeatRegex.ReplaceAllString(str, "")
+	if match := trailingFeatRegex.FindStringSubmatch(str); match != nil {
+		featured = append(featured, featNameSeparator.Split(match[1], -1)...)
+		str = trailingFeatRegex.ReplaceAllString(str, "")
+	}
+	return strings.TrimSpace(str), uniqueNames(featured)
+}
+
+// uniqueNames drops empty and repeated names, comparing them the way
+// candidates are matched.
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := NormalizeForMatch(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+func withoutNames(names []string, remove []string) []string {
+	removed := make(map[string]bool)
+	for _, name := range remove {
+		removed[NormalizeForMatch(name)] = true
+	}
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if !removed[NormalizeForMatch(name)] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// joinNames joins names the way credits are written: "A", "A & B",
+// "A, B & C".
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " & " + names[len(names)-1]
+}
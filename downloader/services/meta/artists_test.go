@@ -0,0 +1,74 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gcottom/yt-dl-services/downloader/config"
+)
+
+func TestSplitFeatured(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		want         string
+		wantFeatured []string
+	}{
+		{"none", "Song", "Song", []string{}},
+		{"bracketed feat.", "Song (feat. Someone)", "Song", []string{"Someone"}},
+		{"square brackets ft", "Song [ft Someone]", "Song", []string{"Someone"}},
+		{"trailing featuring", "Artist featuring Someone", "Artist", []string{"Someone"}},
+		{"several names", "Song (feat. A, B & C)", "Song", []string{"A", "B", "C"}},
+		{"and between names", "Song (feat. A and B)", "Song", []string{"A", "B"}},
+		{"bracketed and trailing", "Song (feat. A) ft. B", "Song", []string{"A", "B"}},
+		{"repeated names", "Song (feat. A) feat. a", "Song", []string{"A"}},
+		{"word containing feat", "Defeated", "Defeated", []string{}},
+		{"word starting with ft", "Song (Ftw)", "Song (Ftw)", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, featured := splitFeatured(tt.in)
+			if got != tt.want || !reflect.DeepEqual(featured, tt.wantFeatured) {
+				t.Errorf("splitFeatured(%q) = %q, %q, want %q, %q", tt.in, got, featured, tt.want, tt.wantFeatured)
+			}
+		})
+	}
+}
+
+func TestCreditArtists(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		in           TrackMeta
+		wantTitle    string
+		wantArtist   string
+		wantArtists  []string
+		wantFeatured []string
+	}{
+		{"no featured artists", "", TrackMeta{Title: "Song", Artist: "Artist"}, "Song", "Artist", []string{"Artist"}, []string{}},
+		{"artist policy by default", "", TrackMeta{Title: "Song (feat. B)", Artist: "A"}, "Song", "A feat. B", []string{"A"}, []string{"B"}},
+		{"unknown policy credits artist", "somewhere", TrackMeta{Title: "Song (feat. B)", Artist: "A"}, "Song", "A feat. B", []string{"A"}, []string{"B"}},
+		{"title policy", FeatPolicyTitle, TrackMeta{Title: "Song", Artist: "A feat. B & C"}, "Song (feat. B & C)", "A", []string{"A"}, []string{"B", "C"}},
+		{"both policy", FeatPolicyBoth, TrackMeta{Title: "Song (feat. B)", Artist: "A"}, "Song (feat. B)", "A feat. B", []string{"A"}, []string{"B"}},
+		{"featured dropped from provider artists", "", TrackMeta{Title: "Song (feat. B)", Artist: "A, B", Artists: []string{"A", "B"}}, "Song", "A feat. B", []string{"A"}, []string{"B"}},
+		{"several main artists", "", TrackMeta{Title: "Song", Artist: "A, B", Artists: []string{"A", "B"}}, "Song", "A, B", []string{"A", "B"}, []string{}},
+		{"already credited", "", TrackMeta{Title: "Song", Artist: "A feat. B", Artists: []string{"A"}, FeaturedArtists: []string{"B"}}, "Song", "A feat. B", []string{"A"}, []string{"B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Metadata.FeatPolicy = tt.policy
+			s := &Service{Config: cfg}
+			got := s.CreditArtists(tt.in)
+			if got.Title != tt.wantTitle || got.Artist != tt.wantArtist {
+				t.Errorf("CreditArtists() = %q by %q, want %q by %q", got.Title, got.Artist, tt.wantTitle, tt.wantArtist)
+			}
+			if !reflect.DeepEqual(got.Artists, tt.wantArtists) || !reflect.DeepEqual(got.FeaturedArtists, tt.wantFeatured) {
+				t.Errorf("CreditArtists() artists = %q, featured %q, want %q, featured %q", got.Artists, got.FeaturedArtists, tt.wantArtists, tt.wantFeatured)
+			}
+			if again := s.CreditArtists(got); !reflect.DeepEqual(again, got) {
+				t.Errorf("CreditArtists() twice = %+v, want %+v", again, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package meta
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"go.uber.org/zap"
+)
+
+// searchCacheKey returns the key the results of a provider search for the
+// given terms are cached under. Terms are normalized so that spelling
+// variants share results, and joined with a separator normalization removes
+// so that no two searches share a key.
+func searchCacheKey(provider string, terms ...string) string {
+	normalized := make([]string, 0, len(terms))
+	for _, term := range terms {
+		normalized = append(normalized, NormalizeForMatch(term))
+	}
+	return provider + ":" + strings.Join(normalized, "|")
+}
+
+// cachedSearch returns the search results stored under key. The cache is
+// best effort: lookup failures are logged and treated as a miss.
+func (s *Service) cachedSearch(ctx context.Context, key string) ([]TrackMeta, bool) {
+	if s.TrackSQL == nil || s.Config.Spotify.SearchCacheTTL <= 0 {
+		return nil, false
+	}
+	value, err := s.TrackSQL.GetCachedSearch(ctx, key)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			zaplog.ErrorC(ctx, "failed to read search cache", zap.Error(err))
+		}
+		return nil, false
+	}
+	var trackMetas []TrackMeta
+	if err := json.Unmarshal([]byte(value), &trackMetas); err != nil {
+		zaplog.ErrorC(ctx, "failed to unmarshal cached search", zap.Error(err))
+		return nil, false
+	}
+	return trackMetas, true
+}
+
+// cacheSearch stores search results under key for the configured TTL.
+func (s *Service) cacheSearch(ctx context.Context, key string, trackMetas []TrackMeta) {
+	if s.TrackSQL == nil || s.Config.Spotify.SearchCacheTTL <= 0 {
+		return
+	}
+	value, err := json.Marshal(trackMetas)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to marshal search results", zap.Error(err))
+		return
+	}
+	ttl := time.Duration(s.Config.Spotify.SearchCacheTTL) * time.Hour
+	if err := s.TrackSQL.PutCachedSearch(ctx, key, string(value), ttl); err != nil {
+		zaplog.ErrorC(ctx, "failed to write search cache", zap.Error(err))
+	}
+}
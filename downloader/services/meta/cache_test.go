@@ -0,0 +1,29 @@
+package meta
+
+import "testing"
+
+func TestSearchCacheKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     []string
+		b     []string
+		equal bool
+	}{
+		{"same search", []string{"Song", "Artist"}, []string{"Song", "Artist"}, true},
+		{"spelling variants", []string{"Beyoncé", "Halo"}, []string{"beyonce", "HALO"}, true},
+		{"words moved between terms", []string{"A B", "C"}, []string{"A", "B C"}, false},
+		{"separator in a term", []string{"A|B", "C"}, []string{"A", "B|C"}, false},
+		{"terms swapped", []string{"Song", "Artist"}, []string{"Artist", "Song"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := searchCacheKey("spotify", tt.a...), searchCacheKey("spotify", tt.b...)
+			if (a == b) != tt.equal {
+				t.Errorf("searchCacheKey(%q) = %q, searchCacheKey(%q) = %q, want equal %v", tt.a, a, tt.b, b, tt.equal)
+			}
+		})
+	}
+	if searchCacheKey("spotify", "Song") == searchCacheKey("musicbrainz", "Song") {
+		t.Error("searchCacheKey is the same for different providers")
+	}
+}
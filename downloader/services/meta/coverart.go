@@ -0,0 +1,267 @@
+package meta
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/fsutil"
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	defaultCoverArtSize     = 1000
+	defaultCoverArtQuality  = 90
+	defaultCoverArtMaxBytes = 10 << 20
+	// maxCoverArtPixels rejects images that would take too much memory to
+	// decode, whatever their compressed size.
+	maxCoverArtPixels = 40_000_000
+	// A row or column is part of a letterbox bar when almost all of its
+	// pixels are darker than barLuma.
+	barLuma          = 32
+	barBrightPercent = 2
+)
+
+// GetCoverArt returns the cover art at url as a square JPEG no larger than
+// the configured size. Processed images are cached on disk by content hash,
+// and an index maps each URL to its image, so the tracks of an album share
+// one download.
+func (s *Service) GetCoverArt(ctx context.Context, url string) ([]byte, error) {
+	maxSize, quality := s.coverArtSettings()
+	key := hashString(fmt.Sprintf("%s|%d|%d", url, maxSize, quality))
+	defer s.lockCoverArt(key)()
+
+	if data, ok := s.cachedCoverArt(key); ok {
+		zaplog.InfoC(ctx, "cover art cache hit", zap.String("url", url))
+		return data, nil
+	}
+	raw, err := s.downloadCoverArt(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ProcessCoverArt(raw, maxSize, quality)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to process cover art", zap.String("url", url), zap.Error(err))
+		return nil, err
+	}
+	if err := s.storeCoverArt(key, data); err != nil {
+		zaplog.ErrorC(ctx, "failed to cache cover art", zap.Error(err))
+	}
+	return data, nil
+}
+
+// coverArtLock serializes the fetches of one image so that tracks of the same
+// album downloaded together only fetch it once.
+type coverArtLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockCoverArt locks the fetch of the image under key and returns the
+// function that unlocks it. The lock is forgotten once no fetch holds or
+// waits for it.
+func (s *Service) lockCoverArt(key string) func() {
+	s.CoverArtLocksMu.Lock()
+	if s.CoverArtLocks == nil {
+		s.CoverArtLocks = make(map[string]*coverArtLock)
+	}
+	lock, ok := s.CoverArtLocks[key]
+	if !ok {
+		lock = new(coverArtLock)
+		s.CoverArtLocks[key] = lock
+	}
+	lock.users++
+	s.CoverArtLocksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		s.CoverArtLocksMu.Lock()
+		defer s.CoverArtLocksMu.Unlock()
+		if lock.users--; lock.users == 0 {
+			delete(s.CoverArtLocks, key)
+		}
+	}
+}
+
+// NormalizeCoverArt processes an image that was not downloaded, such as an
+// upload, with the configured size and quality.
+func (s *Service) NormalizeCoverArt(data []byte) ([]byte, error) {
+	maxSize, quality := s.coverArtSettings()
+	return ProcessCoverArt(data, maxSize, quality)
+}
+
+func (s *Service) coverArtSettings() (int, int) {
+	maxSize := s.Config.CoverArt.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultCoverArtSize
+	}
+	quality := s.Config.CoverArt.Quality
+	if quality <= 0 || quality > 100 {
+		quality = defaultCoverArtQuality
+	}
+	return maxSize, quality
+}
+
+func (s *Service) downloadCoverArt(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.CoverArtHTTPClient.Do(req)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get cover art", zap.String("url", url), zap.Error(err))
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cover art request failed with status %d", response.StatusCode)
+	}
+	maxBytes := s.Config.CoverArt.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultCoverArtMaxBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("cover art is larger than %d bytes", maxBytes)
+	}
+	return data, nil
+}
+
+// ProcessCoverArt centre-crops an image to a square, scales it down to
+// maxSize and encodes it as JPEG. An image that is not square, such as a
+// 16:9 video thumbnail, first has its black letterbox and pillarbox bars
+// cropped. Square images are left whole, as their dark edges are part of
+// the artwork.
+func ProcessCoverArt(data []byte, maxSize int, quality int) ([]byte, error) {
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if imgConfig.Width*imgConfig.Height > maxCoverArtPixels {
+		return nil, fmt.Errorf("cover art is too large: %dx%d", imgConfig.Width, imgConfig.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() != bounds.Dy() {
+		bounds = contentBounds(img)
+	}
+	bounds = squareBounds(bounds)
+	size := bounds.Dx()
+	if size > maxSize {
+		size = maxSize
+	}
+	square := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(square, square.Bounds(), img, bounds, draw.Src, nil)
+	output := new(bytes.Buffer)
+	if err := jpeg.Encode(output, square, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// contentBounds returns the bounds of img without the dark bars YouTube adds
+// around thumbnails that do not fill the frame. An image that is dark all
+// over is returned whole.
+func contentBounds(img image.Image) image.Rectangle {
+	bounds := img.Bounds()
+	top, bottom, left, right := bounds.Min.Y, bounds.Max.Y, bounds.Min.X, bounds.Max.X
+	for top < bottom && isBar(img, bounds.Min.X, bounds.Max.X, top, top+1) {
+		top++
+	}
+	for bottom > top && isBar(img, bounds.Min.X, bounds.Max.X, bottom-1, bottom) {
+		bottom--
+	}
+	if top == bottom {
+		return bounds
+	}
+	for left < right && isBar(img, left, left+1, top, bottom) {
+		left++
+	}
+	for right > left && isBar(img, right-1, right, top, bottom) {
+		right--
+	}
+	return image.Rect(left, top, right, bottom)
+}
+
+func isBar(img image.Image, minX, maxX, minY, maxY int) bool {
+	bright := 0
+	total := (maxX - minX) * (maxY - minY)
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y > barLuma {
+				bright++
+			}
+		}
+	}
+	return bright*100 <= total*barBrightPercent
+}
+
+// squareBounds centre-crops bounds to a square.
+func squareBounds(bounds image.Rectangle) image.Rectangle {
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > height {
+		minX := bounds.Min.X + (width-height)/2
+		return image.Rect(minX, bounds.Min.Y, minX+height, bounds.Max.Y)
+	}
+	minY := bounds.Min.Y + (height-width)/2
+	return image.Rect(bounds.Min.X, minY, bounds.Max.X, minY+width)
+}
+
+// cachedCoverArt follows the index entry for key to the stored image.
+func (s *Service) cachedCoverArt(key string) ([]byte, bool) {
+	if s.Config.CoverArt.CacheDir == "" {
+		return nil, false
+	}
+	blob, err := os.ReadFile(filepath.Join(s.Config.CoverArt.CacheDir, "index", key))
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(s.Config.CoverArt.CacheDir, "blobs", strings.TrimSpace(string(blob))+".jpg"))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// storeCoverArt writes data under its content hash and points key at it.
+func (s *Service) storeCoverArt(key string, data []byte) error {
+	if s.Config.CoverArt.CacheDir == "" {
+		return nil
+	}
+	blob := hashBytes(data)
+	if err := fsutil.WriteFileAtomic(filepath.Join(s.Config.CoverArt.CacheDir, "blobs", blob+".jpg"), data); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(s.Config.CoverArt.CacheDir, "index", key), []byte(blob))
+}
+
+func hashString(str string) string {
+	return hashBytes([]byte(str))
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
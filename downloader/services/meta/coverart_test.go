@@ -0,0 +1,113 @@
+package meta
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+)
+
+var (
+	black = color.RGBA{A: 255}
+	red   = color.RGBA{R: 255, A: 255}
+)
+
+// testImage draws a width×height PNG filled with fill, with content drawn
+// over the given rectangle.
+func testImage(t *testing.T, width, height int, fill color.Color, content image.Rectangle) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (image.Point{X: x, Y: y}).In(content) {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, fill)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
+
+func TestProcessCoverArt(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		maxSize     int
+		wantSize    int
+		cornerIsRed bool
+	}{
+		{"pillarboxed thumbnail loses its bars", testImage(t, 160, 90, black, image.Rect(35, 0, 125, 90)), 1000, 90, true},
+		{"letterboxed thumbnail loses its bars", testImage(t, 160, 120, black, image.Rect(0, 20, 160, 100)), 1000, 80, true},
+		{"wide image without bars is centre-cropped", testImage(t, 160, 90, red, image.Rectangle{}), 1000, 90, true},
+		{"square dark-edged cover is kept whole", testImage(t, 100, 100, black, image.Rect(30, 30, 70, 70)), 1000, 100, false},
+		{"large cover is scaled down", testImage(t, 400, 400, red, image.Rectangle{}), 200, 200, true},
+		{"small cover is not scaled up", testImage(t, 50, 50, red, image.Rectangle{}), 200, 50, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ProcessCoverArt(tt.data, tt.maxSize, 90)
+			if err != nil {
+				t.Fatalf("ProcessCoverArt() error = %v", err)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ProcessCoverArt() did not return a JPEG: %v", err)
+			}
+			if got := img.Bounds(); got.Dx() != tt.wantSize || got.Dy() != tt.wantSize {
+				t.Errorf("ProcessCoverArt() size = %dx%d, want %dx%d", got.Dx(), got.Dy(), tt.wantSize, tt.wantSize)
+			}
+			if got := isRed(img.At(1, 1)); got != tt.cornerIsRed {
+				t.Errorf("ProcessCoverArt() corner is red = %v, want %v", got, tt.cornerIsRed)
+			}
+		})
+	}
+}
+
+func TestGetCoverArtCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(testImage(t, 100, 100, red, image.Rectangle{}))
+	}))
+	defer server.Close()
+	cfg := &config.Config{}
+	cfg.CoverArt.CacheDir = t.TempDir()
+	service := &Service{Config: cfg, CoverArtHTTPClient: server.Client()}
+	ctx := zaplog.CreateAndInject(context.Background())
+
+	first, err := service.GetCoverArt(ctx, server.URL+"/cover.png")
+	if err != nil {
+		t.Fatalf("GetCoverArt() error = %v", err)
+	}
+	second, err := service.GetCoverArt(ctx, server.URL+"/cover.png")
+	if err != nil {
+		t.Fatalf("GetCoverArt() error = %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("GetCoverArt() returned a different image from the cache")
+	}
+	if requests != 1 {
+		t.Errorf("GetCoverArt() fetched the image %d times, want 1", requests)
+	}
+	if len(service.CoverArtLocks) != 0 {
+		t.Errorf("GetCoverArt() kept %d locks after the fetches finished", len(service.CoverArtLocks))
+	}
+}
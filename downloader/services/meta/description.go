@@ -0,0 +1,113 @@
+package meta
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const autoGeneratedPrefix = "Provided to YouTube by "
+
+var (
+	releasedOnRegex = regexp.MustCompile(`^Released on:\s*(\d{4})(-\d{2}-\d{2})?`)
+	yearRegex       = regexp.MustCompile(`\b(\d{4})\b`)
+	creditRegex     = regexp.MustCompile(`^([^:]{2,80}):\s*(.+)$`)
+)
+
+// ParseAutoGeneratedDescription extracts the release details YouTube writes
+// into the description of Topic channel uploads:
+//
+//	Provided to YouTube by <label>
+//
+//	<title> · <artist> · <artist>
+//
+//	<album>
+//
+//	℗ <year> <copyright holder>
+//
+//	Released on: <yyyy-mm-dd>
+//
+//	<role>, <role>: <name>
+//
+//	Auto-generated by YouTube.
+//
+// The second return value is false when the description is not in this form.
+func ParseAutoGeneratedDescription(description string) (DescriptionMeta, bool) {
+	description = strings.ReplaceAll(description, "\r\n", "\n")
+	blocks := make([][]string, 0)
+	for _, block := range strings.Split(description, "\n\n") {
+		lines := make([]string, 0)
+		for _, line := range strings.Split(block, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			blocks = append(blocks, lines)
+		}
+	}
+	if len(blocks) < 2 || !strings.HasPrefix(blocks[0][0], autoGeneratedPrefix) {
+		return DescriptionMeta{}, false
+	}
+
+	parsed := DescriptionMeta{
+		Label:   strings.TrimSpace(strings.TrimPrefix(blocks[0][0], autoGeneratedPrefix)),
+		Credits: make(map[string][]string),
+	}
+	titleParts := strings.Split(blocks[1][0], "·")
+	parsed.Title = strings.TrimSpace(titleParts[0])
+	for _, artist := range titleParts[1:] {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			parsed.Artists = append(parsed.Artists, artist)
+		}
+	}
+	if parsed.Title == "" || len(parsed.Artists) == 0 {
+		return DescriptionMeta{}, false
+	}
+
+	for i, block := range blocks[2:] {
+		for _, line := range block {
+			switch {
+			case strings.HasPrefix(line, "℗"):
+				parsed.Copyright = strings.TrimSpace(strings.TrimPrefix(line, "℗"))
+				if match := yearRegex.FindStringSubmatch(parsed.Copyright); match != nil && parsed.Year == 0 {
+					parsed.Year, _ = strconv.Atoi(match[1])
+				}
+			case releasedOnRegex.MatchString(line):
+				match := releasedOnRegex.FindStringSubmatch(line)
+				parsed.Year, _ = strconv.Atoi(match[1])
+				parsed.ReleaseDate = match[1] + match[2]
+			case line == "Auto-generated by YouTube.":
+			case i == 0 && parsed.Album == "":
+				parsed.Album = line
+			case creditRegex.MatchString(line):
+				match := creditRegex.FindStringSubmatch(line)
+				for _, role := range strings.Split(match[1], ",") {
+					role = strings.TrimSpace(role)
+					parsed.Credits[role] = append(parsed.Credits[role], strings.TrimSpace(match[2]))
+				}
+			}
+		}
+	}
+	return parsed, true
+}
+
+// TrackMeta converts the parsed description into a metadata candidate.
+func (d DescriptionMeta) TrackMeta() TrackMeta {
+	trackMeta := TrackMeta{
+		Title:       d.Title,
+		Artist:      strings.Join(d.Artists, ", "),
+		Artists:     d.Artists,
+		Album:       d.Album,
+		ReleaseDate: d.ReleaseDate,
+		Year:        d.Year,
+		Label:       d.Label,
+		Copyright:   d.Copyright,
+		Composer:    strings.Join(d.Credits["Composer"], "/"),
+		Lyricist:    strings.Join(d.Credits["Lyricist"], "/"),
+	}
+	if len(d.Artists) > 0 {
+		trackMeta.AlbumArtist = d.Artists[0]
+	}
+	return trackMeta
+}
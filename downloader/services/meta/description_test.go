@@ -0,0 +1,84 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+)
+
+const autoGeneratedDescription = `Provided to YouTube by Columbia
+
+Song Title · Main Artist · Second Artist
+
+Album Name
+
+℗ 2019 Columbia Records, a Division of Sony Music Entertainment
+
+Released on: 2019-05-03
+
+Producer: Some Producer
+Composer, Lyricist: Writer One
+Composer: Writer Two
+
+Auto-generated by YouTube.`
+
+func TestParseAutoGeneratedDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        DescriptionMeta
+		wantOK      bool
+	}{
+		{
+			name:        "full description",
+			description: autoGeneratedDescription,
+			want: DescriptionMeta{
+				Label:       "Columbia",
+				Title:       "Song Title",
+				Artists:     []string{"Main Artist", "Second Artist"},
+				Album:       "Album Name",
+				Copyright:   "2019 Columbia Records, a Division of Sony Music Entertainment",
+				ReleaseDate: "2019-05-03",
+				Year:        2019,
+				Credits: map[string][]string{
+					"Producer": {"Some Producer"},
+					"Composer": {"Writer One", "Writer Two"},
+					"Lyricist": {"Writer One"},
+				},
+			},
+			wantOK: true,
+		},
+		{
+			name:        "windows line endings",
+			description: "Provided to YouTube by Label\r\n\r\nSong · Artist\r\n\r\nAlbum\r\n\r\nAuto-generated by YouTube.",
+			want:        DescriptionMeta{Label: "Label", Title: "Song", Artists: []string{"Artist"}, Album: "Album", Credits: map[string][]string{}},
+			wantOK:      true,
+		},
+		{
+			name:        "year from copyright without release date",
+			description: "Provided to YouTube by Label\n\nSong · Artist\n\nAlbum\n\n℗ 2004 Label",
+			want:        DescriptionMeta{Label: "Label", Title: "Song", Artists: []string{"Artist"}, Album: "Album", Copyright: "2004 Label", Year: 2004, Credits: map[string][]string{}},
+			wantOK:      true,
+		},
+		{
+			name:        "release year only",
+			description: "Provided to YouTube by Label\n\nSong · Artist\n\nAlbum\n\nReleased on: 1999",
+			want:        DescriptionMeta{Label: "Label", Title: "Song", Artists: []string{"Artist"}, Album: "Album", ReleaseDate: "1999", Year: 1999, Credits: map[string][]string{}},
+			wantOK:      true,
+		},
+		{"not auto-generated", "Official video for Song by Artist\n\nFollow us on Instagram", DescriptionMeta{}, false},
+		{"missing artists", "Provided to YouTube by Label\n\nSong\n\nAlbum", DescriptionMeta{}, false},
+		{"missing title line", "Provided to YouTube by Label", DescriptionMeta{}, false},
+		{"empty", "", DescriptionMeta{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseAutoGeneratedDescription(tt.description)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseAutoGeneratedDescription() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAutoGeneratedDescription() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
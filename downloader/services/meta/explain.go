@@ -0,0 +1,81 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+)
+
+// ExplainMeta resolves the metadata for a track the same way ResolveMeta
+// does and reports how the result was reached: the variants that were
+// matched against, and why each candidate was selected or rejected.
+func (s *Service) ExplainMeta(ctx context.Context, trackData track_sql.Track) (MetaExplanation, error) {
+	resolution, err := s.ResolveMeta(ctx, trackData)
+	if err != nil {
+		return MetaExplanation{}, err
+	}
+	titles, artists, coverArtist := s.variants(ctx, resolution.YTMeta)
+	explanation := MetaExplanation{
+		YTMeta:      resolution.YTMeta,
+		Titles:      titles,
+		Artists:     artists,
+		CoverArtist: coverArtist,
+		Classes:     s.Rewrite(resolution.YTMeta.Title, resolution.YTMeta.Artist).Classes,
+		Threshold:   s.MatchThreshold(),
+		Candidates:  make([]CandidateExplanation, 0, len(resolution.Candidates)),
+		Final:       resolution.Best,
+	}
+	index, selection := s.chooseCandidate(resolution.Candidates)
+	for i, candidate := range resolution.Candidates {
+		explained := CandidateExplanation{CandidateScore: candidate}
+		switch {
+		case i == index:
+			explained.Selected = true
+			fingerprintScore := max(candidate.Meta.FingerprintScore, identifiedRecordings(resolution.Candidates)[candidate.Meta.MusicBrainzRecordingID])
+			explained.Reason = selectionReason(candidate, selection, fingerprintScore)
+		case candidate.Score < s.MatchThreshold():
+			explained.Reason = s.rejectionReason(candidate)
+		case selection == selectedByTieBreak:
+			selected := resolution.Candidates[index]
+			explained.Reason = fmt.Sprintf("accepted but the %s candidate is the recording its audio fingerprint was identified as; used to fill missing fields", selected.Meta.Provider)
+		default:
+			selected := resolution.Candidates[index]
+			explained.Reason = fmt.Sprintf("accepted but outscored by the %s candidate (weighted %.3f < %.3f); used to fill missing fields", selected.Meta.Provider, candidate.Score*candidate.Meta.Confidence, selected.Score*selected.Meta.Confidence)
+		}
+		explanation.Candidates = append(explanation.Candidates, explained)
+	}
+	explanation.Fallback = index < 0
+	return explanation, nil
+}
+
+func selectionReason(candidate CandidateScore, selection string, fingerprintScore float64) string {
+	switch selection {
+	case selectedByTieBreak:
+		return fmt.Sprintf("within %.2f of the highest weighted score and identified by its audio fingerprint (AcoustID score %.3f)", fingerprintTieMargin, fingerprintScore)
+	case selectedByFingerprint:
+		return fmt.Sprintf("no candidate passed the threshold; identified by its audio fingerprint (AcoustID score %.3f)", fingerprintScore)
+	}
+	return fmt.Sprintf("highest weighted score %.3f (score %.3f × %s confidence %.2f)", candidate.Score*candidate.Meta.Confidence, candidate.Score, candidate.Meta.Provider, candidate.Meta.Confidence)
+}
+
+func (s *Service) rejectionReason(candidate CandidateScore) string {
+	if candidate.Rejected != "" {
+		return candidate.Rejected
+	}
+	reasons := []string{fmt.Sprintf("score %.3f below threshold %.2f", candidate.Score, s.MatchThreshold())}
+	if candidate.TitleScore < s.MatchThreshold() {
+		reasons = append(reasons, fmt.Sprintf("title similarity %.3f", candidate.TitleScore))
+	}
+	if candidate.ArtistScore < s.MatchThreshold() {
+		reasons = append(reasons, fmt.Sprintf("artist similarity %.3f", candidate.ArtistScore))
+	}
+	if candidate.DurationScore < 0.5 {
+		reasons = append(reasons, fmt.Sprintf("duration score %.3f", candidate.DurationScore))
+	}
+	if candidate.VersionPenalty > 0 {
+		reasons = append(reasons, fmt.Sprintf("version mismatch penalty %.2f", candidate.VersionPenalty))
+	}
+	return strings.Join(reasons, "; ")
+}
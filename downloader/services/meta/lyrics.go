@@ -0,0 +1,176 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+const frameLyricsLanguage = "LYRICS_LANGUAGE"
+
+// LyricLine is a line of synced lyrics and when it starts, in milliseconds.
+type LyricLine struct {
+	Time int
+	Text string
+}
+
+// lrcTimestamp matches the [mm:ss.xx] timestamps at the start of an LRC line.
+// Header tags such as [ar:Artist] do not match.
+var lrcTimestamp = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// ParseLRC reads the timed lines of LRC lyrics. A line with several
+// timestamps is repeated at each of them. The returned bool is false when the
+// lyrics have no timed lines, as with plain text.
+func ParseLRC(lrc string) ([]LyricLine, bool) {
+	lines := make([]LyricLine, 0)
+	for _, line := range strings.Split(lrc, "\n") {
+		line = strings.TrimSpace(line)
+		times := make([]int, 0, 1)
+		for {
+			match := lrcTimestamp.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			minutes, _ := strconv.Atoi(match[1])
+			seconds, _ := strconv.Atoi(match[2])
+			ms := minutes*60000 + seconds*1000
+			if match[3] != "" {
+				fraction, _ := strconv.Atoi(match[3])
+				// .x, .xx and .xxx are tenths, hundredths and thousandths.
+				for i := len(match[3]); i < 3; i++ {
+					fraction *= 10
+				}
+				ms += fraction
+			}
+			times = append(times, ms)
+			line = line[len(match[0]):]
+		}
+		for _, start := range times {
+			lines = append(lines, LyricLine{Time: start, Text: strings.TrimSpace(line)})
+		}
+	}
+	return lines, len(lines) > 0
+}
+
+// lyricsText returns the lyrics without their timestamps, for the USLT frame.
+func lyricsText(lyrics string) string {
+	lines, synced := ParseLRC(lyrics)
+	if !synced {
+		return strings.TrimSpace(lyrics)
+	}
+	text := make([]string, 0, len(lines))
+	for _, line := range lines {
+		text = append(text, line.Text)
+	}
+	return strings.Join(text, "\n")
+}
+
+// iso639 maps the BCP 47 languages captions are most often in to the ISO
+// 639-2 codes ID3 lyrics frames use.
+var iso639 = map[string]string{
+	"ar": "ara", "bn": "ben", "cs": "ces", "da": "dan", "de": "deu", "el": "ell",
+	"en": "eng", "es": "spa", "fa": "fas", "fi": "fin", "fil": "fil", "fr": "fra",
+	"he": "heb", "hi": "hin", "hu": "hun", "id": "ind", "it": "ita", "ja": "jpn",
+	"ko": "kor", "ms": "msa", "nl": "nld", "no": "nor", "pa": "pan", "pl": "pol",
+	"pt": "por", "ro": "ron", "ru": "rus", "sv": "swe", "sw": "swa", "ta": "tam",
+	"th": "tha", "tl": "tgl", "tr": "tur", "uk": "ukr", "ur": "urd", "vi": "vie",
+	"yo": "yor", "zh": "zho",
+}
+
+// lyricsLanguage returns the ISO 639-2 code of a BCP 47 language such as
+// pt-BR, or XXX when it is not known.
+func lyricsLanguage(language string) string {
+	base, _, _ := strings.Cut(strings.ToLower(language), "-")
+	if code, ok := iso639[base]; ok {
+		return code
+	}
+	return "XXX"
+}
+
+// addLyricsFrames embeds lyrics as a USLT frame and, when they are timed, a
+// SYLT frame. Files tagged without lyrics keep the lyrics they have.
+func addLyricsFrames(trackMeta TrackMeta, extra *ExtraFrames) {
+	if trackMeta.Lyrics == "" {
+		return
+	}
+	extra.Lyrics = trackMeta.Lyrics
+	extra.LyricsLanguage = lyricsLanguage(trackMeta.LyricsLanguage)
+	extra.UserText[frameLyricsLanguage] = trackMeta.LyricsLanguage
+}
+
+// writeLyricsFrames replaces the lyrics frames of tag.
+func writeLyricsFrames(tag *id3v2.Tag, encoding id3v2.Encoding, lyrics string, language string) {
+	tag.DeleteFrames("USLT")
+	tag.DeleteFrames("SYLT")
+	tag.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{Encoding: encoding, Language: language, Lyrics: lyricsText(lyrics)})
+	if lines, synced := ParseLRC(lyrics); synced {
+		tag.AddFrame("SYLT", syncedLyricsFrame{Encoding: encoding, Language: language, Lines: lines})
+	}
+}
+
+// syncedLyricsFrame is a SYLT frame, which id3v2 can only read as an unknown
+// frame. Times are written in milliseconds.
+type syncedLyricsFrame struct {
+	Encoding id3v2.Encoding
+	Language string
+	Lines    []LyricLine
+}
+
+func (f syncedLyricsFrame) UniqueIdentifier() string {
+	return f.Language
+}
+
+func (f syncedLyricsFrame) Size() int {
+	return len(f.body())
+}
+
+func (f syncedLyricsFrame) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(f.body())
+	return int64(n), err
+}
+
+func (f syncedLyricsFrame) body() []byte {
+	body := new(bytes.Buffer)
+	body.WriteByte(f.Encoding.Key)
+	body.WriteString(f.Language)
+	body.WriteByte(2) // timestamps in milliseconds
+	body.WriteByte(1) // content type: lyrics
+	body.Write(f.encodeText(""))
+	for _, line := range f.Lines {
+		body.Write(f.encodeText(line.Text))
+		binary.Write(body, binary.BigEndian, uint32(line.Time))
+	}
+	return body.Bytes()
+}
+
+// encodeText encodes a terminated string in the frame's encoding.
+func (f syncedLyricsFrame) encodeText(text string) []byte {
+	var encoded []byte
+	switch f.Encoding.Key {
+	case id3v2.EncodingISO.Key:
+		for _, r := range text {
+			if r > 0xff {
+				r = '?'
+			}
+			encoded = append(encoded, byte(r))
+		}
+	case id3v2.EncodingUTF16.Key:
+		encoded = []byte{0xff, 0xfe}
+		for _, unit := range utf16.Encode([]rune(text)) {
+			encoded = binary.LittleEndian.AppendUint16(encoded, unit)
+		}
+	case id3v2.EncodingUTF16BE.Key:
+		for _, unit := range utf16.Encode([]rune(text)) {
+			encoded = binary.BigEndian.AppendUint16(encoded, unit)
+		}
+	default:
+		encoded = []byte(text)
+	}
+	return append(encoded, f.Encoding.TerminationBytes...)
+}
@@ -0,0 +1,52 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLRC(t *testing.T) {
+	tests := []struct {
+		name       string
+		lrc        string
+		want       []LyricLine
+		wantSynced bool
+	}{
+		{"hundredths", "[00:12.34]First line\n[01:02.50]Second line", []LyricLine{{12340, "First line"}, {62500, "Second line"}}, true},
+		{"tenths and thousandths", "[00:01.5]a\n[00:02.005]b", []LyricLine{{1500, "a"}, {2005, "b"}}, true},
+		{"colon fraction", "[00:01:25]a", []LyricLine{{1250, "a"}}, true},
+		{"no fraction", "[02:03]a", []LyricLine{{123000, "a"}}, true},
+		{"repeated line", "[00:01.00][00:05.00]Chorus", []LyricLine{{1000, "Chorus"}, {5000, "Chorus"}}, true},
+		{"header tags skipped", "[ar:Artist]\n[ti:Song]\n[00:01.00]a", []LyricLine{{1000, "a"}}, true},
+		{"windows line endings", "[00:01.00]a\r\n[00:02.00]b\r\n", []LyricLine{{1000, "a"}, {2000, "b"}}, true},
+		{"empty timed line", "[00:01.00]", []LyricLine{{1000, ""}}, true},
+		{"plain text", "First line\nSecond line", []LyricLine{}, false},
+		{"empty", "", []LyricLine{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, synced := ParseLRC(tt.lrc)
+			if synced != tt.wantSynced || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLRC(%q) = %v, %v, want %v, %v", tt.lrc, got, synced, tt.want, tt.wantSynced)
+			}
+		})
+	}
+}
+
+func TestLyricsText(t *testing.T) {
+	tests := []struct {
+		name   string
+		lyrics string
+		want   string
+	}{
+		{"synced", "[ar:Artist]\n[00:01.00]a\n[00:02.00]b\n", "a\nb"},
+		{"plain", "  a\nb\n", "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lyricsText(tt.lyrics); got != tt.want {
+				t.Errorf("lyricsText(%q) = %q, want %q", tt.lyrics, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/retry"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+// musicBrainzOwner is the UFID owner MusicBrainz Picard writes recording IDs
+// under, so players and taggers recognise them.
+const musicBrainzOwner = "http://musicbrainz.org"
+
+const musicBrainzSearchLimit = 10
+
+var featJoinPhraseRegex = regexp.MustCompile(`(?i)\b(?:feat\.?|ft\.?|featuring)(?:\s|$)`)
+
+var luceneEscaper = strings.NewReplacer(
+	`\`, `\\`, `+`, `\+`, `-`, `\-`, `!`, `\!`, `(`, `\(`, `)`, `\)`, `{`, `\{`, `}`, `\}`,
+	`[`, `\[`, `]`, `\]`, `^`, `\^`, `"`, `\"`, `~`, `\~`, `*`, `\*`, `?`, `\?`, `:`, `\:`,
+	`/`, `\/`, `&`, `\&`, `|`, `\|`,
+)
+
+// MusicBrainzProvider searches MusicBrainz recordings by ISRC when one is
+// known and by title, artist and duration otherwise. Requests are spaced by
+// the configured interval because MusicBrainz blocks clients that exceed one
+// request per second.
+type MusicBrainzProvider struct {
+	Service *Service
+	Limiter *RateLimiter
+}
+
+func (p *MusicBrainzProvider) Name() string {
+	return "musicbrainz"
+}
+
+func (p *MusicBrainzProvider) Search(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) ([]TrackMeta, error) {
+	if trackMeta.ISRC != "" {
+		results, err := p.searchWithRetry(ctx, "isrc:"+luceneEscaper.Replace(trackMeta.ISRC))
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > 0 {
+			return results, nil
+		}
+	}
+	sanitizedTitle := p.Service.SanitizeString(p.Service.SanitizeParenthesis(trackMeta.Title))
+	terms := []string{fmt.Sprintf("recording:(%s)", luceneEscaper.Replace(sanitizedTitle))}
+	if artist := p.Service.SanitizeAuthor(trackMeta.Artist); artist != "" {
+		terms = append(terms, fmt.Sprintf("artist:(%s)", luceneEscaper.Replace(artist)))
+	}
+	if trackMeta.Duration > 0 {
+		tolerance := p.Service.durationTolerance()
+		terms = append(terms, fmt.Sprintf("dur:[%d TO %d]", trackMeta.Duration-tolerance*1000, trackMeta.Duration+tolerance*1000))
+	}
+	return p.searchWithRetry(ctx, strings.Join(terms, " "))
+}
+
+func (p *MusicBrainzProvider) searchWithRetry(ctx context.Context, query string) ([]TrackMeta, error) {
+	res, err := retry.Retry(retry.NewAlgSimpleDefault(), 3, p.searchRecordings, ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return res[0].([]TrackMeta), nil
+}
+
+func (p *MusicBrainzProvider) searchRecordings(ctx context.Context, query string) ([]TrackMeta, error) {
+	zaplog.InfoC(ctx, "searching musicbrainz", zap.String("query", query))
+	if err := p.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/recording?fmt=json&limit=%d&query=%s", strings.TrimRight(p.Service.Config.MusicBrainz.Endpoint, "/"), musicBrainzSearchLimit, url.QueryEscape(query))
+	req, err := p.Service.HTTPClient.CreateRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to create musicbrainz request", zap.Error(err))
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", p.Service.Config.MusicBrainz.UserAgent)
+	res, status, err := p.Service.HTTPClient.DoRequest(req)
+	if err != nil {
+		zaplog.ErrorC(ctx, "error while sending musicbrainz request", zap.Error(err))
+		return nil, err
+	}
+	if status != http.StatusOK {
+		zaplog.ErrorC(ctx, "musicbrainz request failed", zap.Int("status", status))
+		return nil, fmt.Errorf("musicbrainz request failed with status %d", status)
+	}
+	var response MusicBrainzRecordingResponse
+	if err = json.Unmarshal(res, &response); err != nil {
+		zaplog.ErrorC(ctx, "failed to unmarshal musicbrainz response", zap.Error(err))
+		return nil, err
+	}
+	trackMetas := make([]TrackMeta, 0, len(response.Recordings))
+	for _, recording := range response.Recordings {
+		trackMetas = append(trackMetas, p.recordingMeta(recording))
+	}
+	return trackMetas, nil
+}
+
+// recordingMeta converts a recording into a candidate, taking the album
+// details from its earliest official release.
+func (p *MusicBrainzProvider) recordingMeta(recording MusicBrainzRecording) TrackMeta {
+	artists, artistIDs := recording.ArtistCredit.names()
+	mainArtists, featured := recording.ArtistCredit.split()
+	trackMeta := TrackMeta{
+		Title:                  recording.Title,
+		Artist:                 strings.Join(artists, ", "),
+		Artists:                mainArtists,
+		FeaturedArtists:        featured,
+		Duration:               recording.Length,
+		MusicBrainzRecordingID: recording.ID,
+		MusicBrainzArtistID:    strings.Join(artistIDs, "/"),
+	}
+	if len(recording.ISRCs) > 0 {
+		trackMeta.ISRC = recording.ISRCs[0]
+	}
+	release, ok := preferredRelease(recording.Releases)
+	if !ok {
+		return trackMeta
+	}
+	albumArtists, albumArtistIDs := release.ArtistCredit.names()
+	if len(albumArtists) == 0 {
+		albumArtists, albumArtistIDs = artists, artistIDs
+	}
+	trackMeta.Album = release.Title
+	trackMeta.AlbumArtist = strings.Join(albumArtists, ", ")
+	trackMeta.MusicBrainzReleaseID = release.ID
+	trackMeta.MusicBrainzReleaseGroupID = release.ReleaseGroup.ID
+	trackMeta.MusicBrainzAlbumArtistID = strings.Join(albumArtistIDs, "/")
+	trackMeta.ReleaseDate = release.Date
+	if len(release.Date) >= 4 {
+		trackMeta.Year, _ = strconv.Atoi(release.Date[:4])
+	}
+	if len(release.Media) > 0 {
+		trackMeta.DiscNumber = release.Media[0].Position
+		if len(release.Media[0].Track) > 0 {
+			trackMeta.TrackNumber, _ = strconv.Atoi(release.Media[0].Track[0].Number)
+		}
+	}
+	if endpoint := p.Service.Config.MusicBrainz.CoverArtEndpoint; endpoint != "" {
+		trackMeta.CoverArtURL = fmt.Sprintf("%s/release/%s/front-500", strings.TrimRight(endpoint, "/"), release.ID)
+	}
+	return trackMeta
+}
+
+// preferredRelease picks the earliest official release, falling back to the
+// earliest release of any status.
+func preferredRelease(releases []MusicBrainzRelease) (MusicBrainzRelease, bool) {
+	var best *MusicBrainzRelease
+	for i := range releases {
+		release := &releases[i]
+		if best == nil {
+			best = release
+			continue
+		}
+		official, bestOfficial := release.Status == "Official", best.Status == "Official"
+		if official != bestOfficial {
+			if official {
+				best = release
+			}
+			continue
+		}
+		if release.Date != "" && (best.Date == "" || release.Date < best.Date) {
+			best = release
+		}
+	}
+	if best == nil {
+		return MusicBrainzRelease{}, false
+	}
+	return *best, true
+}
+
+func (credits MusicBrainzArtistCredits) names() ([]string, []string) {
+	names := make([]string, 0, len(credits))
+	ids := make([]string, 0, len(credits))
+	for _, credit := range credits {
+		names = append(names, credit.Name)
+		ids = append(ids, credit.Artist.ID)
+	}
+	return names, ids
+}
+
+// split separates the main artists from those credited after a "feat."
+// join phrase.
+func (credits MusicBrainzArtistCredits) split() ([]string, []string) {
+	artists := make([]string, 0, len(credits))
+	featured := make([]string, 0)
+	featuring := false
+	for _, credit := range credits {
+		if featuring {
+			featured = append(featured, credit.Name)
+		} else {
+			artists = append(artists, credit.Name)
+		}
+		featuring = featuring || featJoinPhraseRegex.MatchString(credit.JoinPhrase)
+	}
+	return artists, featured
+}
+
+// addMusicBrainzFrames adds the IDs MusicBrainz Picard writes, so libraries
+// tagged by either tool can be matched up later. Missing IDs clear the frame.
+func addMusicBrainzFrames(trackMeta TrackMeta, extra ExtraFrames) {
+	extra.UniqueFileIDs[musicBrainzOwner] = trackMeta.MusicBrainzRecordingID
+	extra.UserText["MusicBrainz Album Id"] = trackMeta.MusicBrainzReleaseID
+	extra.UserText["MusicBrainz Release Group Id"] = trackMeta.MusicBrainzReleaseGroupID
+	extra.UserText["MusicBrainz Artist Id"] = trackMeta.MusicBrainzArtistID
+	extra.UserText["MusicBrainz Album Artist Id"] = trackMeta.MusicBrainzAlbumArtistID
+}
+
+// RateLimiter spaces calls to Wait at least Interval apart.
+type RateLimiter struct {
+	Interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{Interval: interval}
+}
+
+// Wait blocks until the caller may send its request or ctx is done.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next = slot.Add(r.Interval)
+	r.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
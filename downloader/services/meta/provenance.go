@@ -0,0 +1,106 @@
+package meta
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+)
+
+// PipelineVersion identifies the build that tagged a file. It is set at build
+// time with -ldflags "-X .../services/meta.PipelineVersion=...".
+var PipelineVersion = "dev"
+
+// TXXX descriptions of the provenance frames.
+const (
+	frameVideoID         = "YOUTUBE_ID"
+	frameSourceID        = "YOUTUBE_SOURCE_ID"
+	frameURL             = "YOUTUBE_URL"
+	frameDownloadDate    = "DOWNLOAD_DATE"
+	framePipelineVersion = "PIPELINE_VERSION"
+	frameMatchScore      = "MATCH_SCORE"
+)
+
+// frameVersion is the TXXX description of the version of a recording, such
+// as Live or Acoustic.
+const frameVersion = "VERSION"
+
+// Provenance records where a saved file came from.
+type Provenance struct {
+	VideoID         string  `json:"videoID"`
+	SourceID        string  `json:"sourceID"` // the video the audio came from, if not VideoID
+	URL             string  `json:"url"`
+	DownloadDate    string  `json:"downloadDate"` // RFC 3339
+	PipelineVersion string  `json:"pipelineVersion"`
+	MatchScore      float64 `json:"matchScore"`
+}
+
+// TaggedTrack is what can be read back from a saved file.
+type TaggedTrack struct {
+	Meta       TrackMeta
+	Provenance Provenance
+}
+
+func videoURL(id string) string {
+	return "https://www.youtube.com/watch?v=" + id
+}
+
+// addProvenanceFrames records the video a file was downloaded from. The
+// download date of a file that already has one is kept, so retagging does not
+// make a file look newer than it is. A date that is not RFC 3339, as another
+// tagger may have written, is replaced.
+func addProvenanceFrames(data []byte, trackData track_sql.Track, trackMeta TrackMeta, extra *ExtraFrames) {
+	downloadDate := time.Now().UTC()
+	if tagged, err := ReadTaggedTrack(data); err == nil {
+		if kept, err := time.Parse(time.RFC3339, tagged.Provenance.DownloadDate); err == nil {
+			downloadDate = kept
+		}
+	}
+	sourceID := ""
+	if trackData.SourceVideoID() != trackData.ID {
+		sourceID = trackData.SourceVideoID()
+	}
+	extra.UserText[frameVideoID] = trackData.ID
+	extra.UserText[frameSourceID] = sourceID
+	extra.UserText[frameURL] = videoURL(trackData.SourceVideoID())
+	extra.UserText[frameDownloadDate] = downloadDate.Format(time.RFC3339)
+	extra.UserText[framePipelineVersion] = PipelineVersion
+	extra.UserText[frameMatchScore] = strconv.FormatFloat(trackMeta.MatchScore, 'f', 3, 64)
+	extra.Comment = fmt.Sprintf("Downloaded from %s on %s", videoURL(trackData.SourceVideoID()), downloadDate.Format(time.DateOnly))
+}
+
+// ReadTaggedTrack reads the metadata and provenance a file was tagged with.
+func ReadTaggedTrack(data []byte) (TaggedTrack, error) {
+	tag, err := id3v2.ParseReader(bytes.NewReader(data), id3v2.Options{Parse: true})
+	if err != nil {
+		return TaggedTrack{}, err
+	}
+	userText := make(map[string]string)
+	for _, frame := range tag.GetFrames("TXXX") {
+		if text, ok := frame.(id3v2.UserDefinedTextFrame); ok {
+			userText[text.Description] = text.Value
+		}
+	}
+	tagged := TaggedTrack{
+		Meta: TrackMeta{
+			Title:   tag.Title(),
+			Artist:  displayTextValues(tag.Version(), tag.Artist()),
+			Album:   tag.Album(),
+			Genre:   tag.Genre(),
+			Version: userText[frameVersion],
+		},
+		Provenance: Provenance{
+			VideoID:         userText[frameVideoID],
+			SourceID:        userText[frameSourceID],
+			URL:             userText[frameURL],
+			DownloadDate:    userText[frameDownloadDate],
+			PipelineVersion: userText[framePipelineVersion],
+		},
+	}
+	tagged.Meta.Duration, _ = strconv.Atoi(tag.GetTextFrame("TLEN").Text)
+	tagged.Provenance.MatchScore, _ = strconv.ParseFloat(userText[frameMatchScore], 64)
+	return tagged, nil
+}
@@ -0,0 +1,84 @@
+package meta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+)
+
+// audio stands in for the MPEG frames after the tag.
+var audio = append([]byte{0xFF, 0xFB, 0x90, 0x64}, make([]byte, 412)...)
+
+// tagProvenance writes the provenance frames of track into data and returns
+// the result.
+func tagProvenance(t *testing.T, data []byte, track track_sql.Track, trackMeta TrackMeta) []byte {
+	t.Helper()
+	extra := ExtraFrames{UserText: make(map[string]string)}
+	addProvenanceFrames(data, track, trackMeta, &extra)
+	tagged, err := writeExtraFrames(data, extra)
+	if err != nil {
+		t.Fatalf("writeExtraFrames() error = %v", err)
+	}
+	return tagged
+}
+
+func TestProvenanceRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		track track_sql.Track
+		want  Provenance
+	}{
+		{"music video", track_sql.Track{ID: "abc"}, Provenance{VideoID: "abc", URL: "https://www.youtube.com/watch?v=abc", PipelineVersion: PipelineVersion, MatchScore: 0.877}},
+		{"art track", track_sql.Track{ID: "abc", SourceID: "def"}, Provenance{VideoID: "abc", SourceID: "def", URL: "https://www.youtube.com/watch?v=def", PipelineVersion: PipelineVersion, MatchScore: 0.877}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().UTC().Truncate(time.Second)
+			got, err := ReadTaggedTrack(tagProvenance(t, audio, tt.track, TrackMeta{MatchScore: 0.8766}))
+			if err != nil {
+				t.Fatalf("ReadTaggedTrack() error = %v", err)
+			}
+			downloaded, err := time.Parse(time.RFC3339, got.Provenance.DownloadDate)
+			if err != nil || downloaded.Before(before) {
+				t.Errorf("DownloadDate = %q, want the time it was tagged", got.Provenance.DownloadDate)
+			}
+			got.Provenance.DownloadDate = ""
+			if got.Provenance != tt.want {
+				t.Errorf("ReadTaggedTrack() provenance = %+v, want %+v", got.Provenance, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvenanceKeepsDownloadDate(t *testing.T) {
+	track := track_sql.Track{ID: "abc"}
+	tests := []struct {
+		name string
+		date string
+		kept bool
+	}{
+		{"earlier download", "2020-01-02T03:04:05Z", true},
+		{"date only", "2020-01-02", false},
+		{"short date", "2020", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := writeExtraFrames(audio, ExtraFrames{UserText: map[string]string{frameDownloadDate: tt.date}})
+			if err != nil {
+				t.Fatalf("writeExtraFrames() error = %v", err)
+			}
+			got, err := ReadTaggedTrack(tagProvenance(t, data, track, TrackMeta{}))
+			if err != nil {
+				t.Fatalf("ReadTaggedTrack() error = %v", err)
+			}
+			if _, err := time.Parse(time.RFC3339, got.Provenance.DownloadDate); err != nil {
+				t.Errorf("DownloadDate = %q is not RFC 3339", got.Provenance.DownloadDate)
+			}
+			if kept := got.Provenance.DownloadDate == tt.date; kept != tt.kept {
+				t.Errorf("DownloadDate = %q, want kept %v", got.Provenance.DownloadDate, tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"go.uber.org/zap"
+)
+
+// defaultProviders is the chain used when the config does not list any.
+var defaultProviders = []config.ProviderConfig{
+	{Name: "description", Enabled: true, Confidence: 1.0},
+	{Name: "spotify", Enabled: true, Confidence: 0.9},
+	{Name: "musicbrainz", Enabled: true, Confidence: 0.95},
+}
+
+// buildProviderChain creates the enabled providers in config order. Providers
+// that cannot run with the current config are skipped with a warning rather
+// than failing every track later.
+func (s *Service) buildProviderChain() []ChainedProvider {
+	providerConfigs := s.Config.Metadata.Providers
+	if len(providerConfigs) == 0 {
+		providerConfigs = defaultProviders
+	}
+	chain := make([]ChainedProvider, 0)
+	for _, providerConfig := range providerConfigs {
+		if !providerConfig.Enabled {
+			continue
+		}
+		provider, err := s.newProvider(providerConfig.Name)
+		if err != nil {
+			zaplog.Warn("skipping metadata provider", zap.String("provider", providerConfig.Name), zap.Error(err))
+			continue
+		}
+		confidence := providerConfig.Confidence
+		if confidence <= 0 {
+			confidence = 1
+		}
+		chain = append(chain, ChainedProvider{Provider: provider, Confidence: confidence})
+	}
+	return chain
+}
+
+func (s *Service) newProvider(name string) (MetadataProvider, error) {
+	switch name {
+	case "description":
+		return &DescriptionProvider{}, nil
+	case "spotify":
+		if s.Config.Spotify.ClientID == "" || s.Config.Spotify.ClientSecret == "" {
+			return nil, errors.New("spotify client credentials are not configured")
+		}
+		return &SpotifyProvider{Service: s}, nil
+	case "musicbrainz":
+		if s.Config.MusicBrainz.Endpoint == "" || s.Config.MusicBrainz.UserAgent == "" {
+			return nil, errors.New("musicbrainz endpoint and user agent are not configured")
+		}
+		interval := time.Duration(s.Config.MusicBrainz.RequestInterval) * time.Millisecond
+		if interval <= 0 {
+			interval = time.Second
+		}
+		return &MusicBrainzProvider{Service: s, Limiter: NewRateLimiter(interval)}, nil
+	case "acoustid":
+		if s.Config.AcoustID.Endpoint == "" || s.Config.AcoustID.APIKey == "" {
+			return nil, errors.New("acoustid endpoint and api key are not configured")
+		}
+		if !s.Config.Fingerprint.Enabled {
+			return nil, errors.New("acoustid needs fingerprinting to be enabled")
+		}
+		return &AcoustIDProvider{Service: s, Limiter: NewRateLimiter(334 * time.Millisecond)}, nil
+	}
+	return nil, fmt.Errorf("unknown metadata provider %q", name)
+}
+
+// SearchProviders queries every provider in the chain and returns all of
+// their candidates tagged with the provider name and confidence. A failing
+// provider is logged and skipped so the others can still match. Once an
+// earlier provider has a match with an ISRC, later providers are given it so
+// they can look the recording up directly.
+func (s *Service) SearchProviders(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) []TrackMeta {
+	candidates := make([]TrackMeta, 0)
+	searched := 0
+	var titles, artists []string
+	for _, chained := range s.Providers {
+		if trackMeta.ISRC == "" && len(candidates) > searched {
+			searched = len(candidates)
+			if titles == nil {
+				titles, artists, _ = s.variants(ctx, trackMeta)
+			}
+			if isrc := s.matchedISRC(titles, artists, trackMeta, candidates); isrc != "" {
+				zaplog.InfoC(ctx, "using isrc from earlier match", zap.String("isrc", isrc))
+				trackMeta.ISRC = isrc
+			}
+		}
+		results, err := chained.Provider.Search(ctx, trackMeta, trackData)
+		if err != nil {
+			zaplog.ErrorC(ctx, "metadata provider failed", zap.String("provider", chained.Provider.Name()), zap.Error(err))
+			continue
+		}
+		for _, result := range results {
+			result.Provider = chained.Provider.Name()
+			result.Confidence = chained.Confidence
+			candidates = append(candidates, result)
+		}
+	}
+	return candidates
+}
+
+// matchedISRC returns the ISRC of the most trusted candidate with one that
+// clears the match threshold. Unlike a full match, nothing is merged or
+// logged.
+func (s *Service) matchedISRC(titles []string, artists []string, trackMeta TrackMeta, candidates []TrackMeta) string {
+	isrc, best := "", 0.0
+	for _, candidate := range candidates {
+		if candidate.ISRC == "" {
+			continue
+		}
+		score := s.scoreCandidate(titles, artists, trackMeta.Title, trackMeta.Duration, candidate)
+		if weighted := score.Score * candidate.Confidence; score.Score >= s.MatchThreshold() && weighted > best {
+			isrc, best = candidate.ISRC, weighted
+		}
+	}
+	return isrc
+}
+
+// mergeCandidates fills the fields best is missing from the other accepted
+// candidates, most trusted first.
+func mergeCandidates(best TrackMeta, accepted []CandidateScore) TrackMeta {
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].Score*accepted[i].Meta.Confidence > accepted[j].Score*accepted[j].Meta.Confidence
+	})
+	for _, candidate := range accepted {
+		other := candidate.Meta
+		if best.Album == "" {
+			best.Album = other.Album
+		}
+		if best.AlbumArtist == "" {
+			best.AlbumArtist = other.AlbumArtist
+		}
+		if best.CoverArtURL == "" {
+			best.CoverArtURL = other.CoverArtURL
+		}
+		if best.TrackNumber == 0 {
+			best.TrackNumber = other.TrackNumber
+		}
+		if best.DiscNumber == 0 {
+			best.DiscNumber = other.DiscNumber
+		}
+		if best.ReleaseDate == "" {
+			best.ReleaseDate = other.ReleaseDate
+		}
+		if best.Year == 0 {
+			best.Year = other.Year
+		}
+		if best.ISRC == "" {
+			best.ISRC = other.ISRC
+		}
+		if best.Duration == 0 {
+			best.Duration = other.Duration
+		}
+		if best.Label == "" {
+			best.Label = other.Label
+		}
+		if best.Copyright == "" {
+			best.Copyright = other.Copyright
+		}
+		if best.Composer == "" {
+			best.Composer = other.Composer
+		}
+		if best.Lyricist == "" {
+			best.Lyricist = other.Lyricist
+		}
+		best.Explicit = best.Explicit || other.Explicit
+		if best.MusicBrainzRecordingID == "" && other.MusicBrainzRecordingID != "" {
+			best.MusicBrainzRecordingID = other.MusicBrainzRecordingID
+			best.MusicBrainzArtistID = other.MusicBrainzArtistID
+		}
+		// Release IDs only carry over when they describe the same album.
+		if best.MusicBrainzReleaseID == "" && other.MusicBrainzReleaseID != "" && NormalizeForMatch(best.Album) == NormalizeForMatch(other.Album) {
+			best.MusicBrainzReleaseID = other.MusicBrainzReleaseID
+			best.MusicBrainzReleaseGroupID = other.MusicBrainzReleaseGroupID
+			best.MusicBrainzAlbumArtistID = other.MusicBrainzAlbumArtistID
+		}
+	}
+	return best
+}
+
+// SpotifyProvider searches the Spotify catalog. When the raw YouTube title
+// finds nothing it retries with the sanitized title and any cover artist.
+type SpotifyProvider struct {
+	Service *Service
+}
+
+func (p *SpotifyProvider) Name() string {
+	return "spotify"
+}
+
+func (p *SpotifyProvider) Search(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) ([]TrackMeta, error) {
+	results, err := p.Service.GetSpotifyMeta(ctx, trackMeta)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > 0 {
+		return results, nil
+	}
+	sanitizedTitle := p.Service.SanitizeString(p.Service.SanitizeParenthesis(trackMeta.Title))
+	results, err = p.Service.GetSpotifyMeta(ctx, TrackMeta{Title: sanitizedTitle, Artist: trackMeta.Artist})
+	if err != nil {
+		return nil, err
+	}
+	if coverArtist := p.Service.CoverArtistCheck(ctx, trackMeta.Title); coverArtist != "" {
+		coverResults, err := p.Service.GetSpotifyMeta(ctx, TrackMeta{Title: sanitizedTitle, Artist: coverArtist})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, coverResults...)
+	}
+	return results, nil
+}
+
+// DescriptionProvider turns the description of an auto-generated "Provided to
+// YouTube by" upload into a candidate. It needs no network access and is
+// authoritative for the release it describes.
+type DescriptionProvider struct{}
+
+func (p *DescriptionProvider) Name() string {
+	return "description"
+}
+
+func (p *DescriptionProvider) Search(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) ([]TrackMeta, error) {
+	parsed, ok := ParseAutoGeneratedDescription(trackData.Description)
+	if !ok {
+		return nil, nil
+	}
+	zaplog.InfoC(ctx, "parsed auto-generated description", zap.String("album", parsed.Album), zap.String("label", parsed.Label))
+	return []TrackMeta{parsed.TrackMeta()}, nil
+}
+
+// WithOverrides returns t with every field that is set in overrides replaced.
+// Provider bookkeeping (provider, confidence, match score) is kept from t.
+func (t TrackMeta) WithOverrides(overrides TrackMeta) TrackMeta {
+	setString := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	setInt := func(field *int, value int) {
+		if value != 0 {
+			*field = value
+		}
+	}
+	setString(&t.Title, overrides.Title)
+	setString(&t.Artist, overrides.Artist)
+	if overrides.Artist != "" || len(overrides.Artists) > 0 {
+		t.Artists = overrides.Artists
+		t.FeaturedArtists = overrides.FeaturedArtists
+	}
+	setString(&t.Album, overrides.Album)
+	setString(&t.AlbumArtist, overrides.AlbumArtist)
+	setString(&t.Genre, overrides.Genre)
+	setString(&t.Version, overrides.Version)
+	setString(&t.CoverArtURL, overrides.CoverArtURL)
+	if len(overrides.CoverArt) > 0 {
+		t.CoverArt = overrides.CoverArt
+	} else if overrides.CoverArtURL != "" {
+		t.CoverArt = nil
+	}
+	setInt(&t.TrackNumber, overrides.TrackNumber)
+	setInt(&t.DiscNumber, overrides.DiscNumber)
+	setString(&t.ReleaseDate, overrides.ReleaseDate)
+	setInt(&t.Year, overrides.Year)
+	setString(&t.ISRC, overrides.ISRC)
+	setInt(&t.Duration, overrides.Duration)
+	t.Explicit = t.Explicit || overrides.Explicit
+	setString(&t.Label, overrides.Label)
+	setString(&t.Copyright, overrides.Copyright)
+	setString(&t.Composer, overrides.Composer)
+	setString(&t.Lyricist, overrides.Lyricist)
+	setString(&t.MusicBrainzRecordingID, overrides.MusicBrainzRecordingID)
+	setString(&t.MusicBrainzReleaseID, overrides.MusicBrainzReleaseID)
+	setString(&t.MusicBrainzReleaseGroupID, overrides.MusicBrainzReleaseGroupID)
+	setString(&t.MusicBrainzArtistID, overrides.MusicBrainzArtistID)
+	setString(&t.MusicBrainzAlbumArtistID, overrides.MusicBrainzAlbumArtistID)
+	return t
+}
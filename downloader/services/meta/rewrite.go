@@ -0,0 +1,178 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidRewriteRule = errors.New("invalid rewrite rule")
+
+const (
+	RewriteFieldTitle  = "title"
+	RewriteFieldArtist = "artist"
+)
+
+const (
+	ClassCover        = "cover"
+	ClassRemix        = "remix"
+	ClassLive         = "live"
+	ClassInstrumental = "instrumental"
+)
+
+// coverArtistGroup is the named group a cover rule captures the cover artist
+// with.
+const coverArtistGroup = "artist"
+
+func replaceWith(str string) *string {
+	return &str
+}
+
+// defaultRewriteRules is the rule set used when the config does not list any.
+// Brackets that name a version, such as a remix or live recording, are
+// unwrapped so the version survives the final rule, which drops any other
+// bracketed text.
+var defaultRewriteRules = []config.RewriteRule{
+	{Name: "cover by", Field: RewriteFieldTitle, Match: `(?i)[(\[（【［]\s*cover(?:ed)?\s+by\s+(?P<artist>[^()\[\]（）【】［］]*?)\s*[)\]）】］]`, Replace: replaceWith(""), Classify: ClassCover},
+	{Name: "artist cover", Field: RewriteFieldTitle, Match: `(?i)[(\[（【［]\s*(?P<artist>[^()\[\]（）【】［］]*?)\s*\bcover\s*[)\]）】］]`, Replace: replaceWith(""), Classify: ClassCover},
+	{Name: "remix", Field: RewriteFieldTitle, Match: `(?i)[(\[（【［]([^()\[\]（）【】［］]*\b(?:remix|rmx|bootleg|rework)\b[^()\[\]（）【】［］]*)[)\]）】］]`, Replace: replaceWith(" ${1}"), Classify: ClassRemix},
+	{Name: "live", Field: RewriteFieldTitle, Match: `(?i)[(\[（【［]([^()\[\]（）【】［］]*\blive\b[^()\[\]（）【】［］]*)[)\]）】］]`, Replace: replaceWith(" ${1}"), Classify: ClassLive},
+	{Name: "live at", Field: RewriteFieldTitle, Match: `(?i)\blive (?:at|from|in)\b`, Classify: ClassLive},
+	{Name: "instrumental", Field: RewriteFieldTitle, Match: `(?i)[(\[（【［]([^()\[\]（）【】［］]*\b(?:instrumental|karaoke|off vocal)\b[^()\[\]（）【】［］]*)[)\]）】］]`, Replace: replaceWith(" ${1}"), Classify: ClassInstrumental},
+	{Name: "version", Field: RewriteFieldTitle, Match: `(?i)[(\[（【［]([^()\[\]（）【】［］]*\b(?:acoustic|unplugged|demo|extended|radio edit|sped up|slowed)\b[^()\[\]（）【】［］]*)[)\]）】］]`, Replace: replaceWith(" ${1}")},
+	{Name: "brackets", Field: RewriteFieldTitle, Match: `\([^()]*\)|\[[^\[\]]*\]|（[^（）]*）|【[^【】]*】|［[^［］]*］`, Replace: replaceWith("")},
+	{Name: "channel suffix", Field: RewriteFieldArtist, Match: `(?i)(?:\s*-\s*|\s+)(?:official|topic)\b|\s*-?\s*vevo\b`, Replace: replaceWith("")},
+	{Name: "handle", Field: RewriteFieldArtist, Match: `@`, Replace: replaceWith("")},
+}
+
+// RewriteRule is a compiled config.RewriteRule.
+type RewriteRule struct {
+	Name     string
+	Field    string
+	Regex    *regexp.Regexp
+	Replace  *string
+	Classify string
+}
+
+// RewriteResult is a title and artist after every rule has run.
+type RewriteResult struct {
+	Title       string        `json:"title"`
+	Artist      string        `json:"artist"`
+	CoverArtist string        `json:"coverArtist"`
+	Classes     []string      `json:"classes"`
+	Applied     []AppliedRule `json:"applied"`
+}
+
+// AppliedRule records a rule that matched and what it did.
+type AppliedRule struct {
+	Name     string `json:"name"`
+	Field    string `json:"field"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+	Classify string `json:"classify,omitempty"`
+}
+
+// buildRewriteRules compiles the configured rules. Rules that do not compile
+// are skipped with a warning so the rest still apply.
+func (s *Service) buildRewriteRules() []RewriteRule {
+	ruleConfigs := s.Config.Rewrite.Rules
+	if len(ruleConfigs) == 0 {
+		ruleConfigs = defaultRewriteRules
+	}
+	rules := make([]RewriteRule, 0, len(ruleConfigs))
+	for _, ruleConfig := range ruleConfigs {
+		rule, err := compileRewriteRule(ruleConfig)
+		if err != nil {
+			zaplog.Warn("skipping rewrite rule", zap.String("rule", ruleConfig.Name), zap.Error(err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func compileRewriteRule(ruleConfig config.RewriteRule) (RewriteRule, error) {
+	if ruleConfig.Field != RewriteFieldTitle && ruleConfig.Field != RewriteFieldArtist {
+		return RewriteRule{}, fmt.Errorf("%w %q: unknown field %q", ErrInvalidRewriteRule, ruleConfig.Name, ruleConfig.Field)
+	}
+	switch ruleConfig.Classify {
+	case "", ClassCover, ClassRemix, ClassLive, ClassInstrumental:
+	default:
+		return RewriteRule{}, fmt.Errorf("%w %q: unknown class %q", ErrInvalidRewriteRule, ruleConfig.Name, ruleConfig.Classify)
+	}
+	regex, err := regexp.Compile(ruleConfig.Match)
+	if err != nil {
+		return RewriteRule{}, fmt.Errorf("%w %q: %v", ErrInvalidRewriteRule, ruleConfig.Name, err)
+	}
+	return RewriteRule{
+		Name:     ruleConfig.Name,
+		Field:    ruleConfig.Field,
+		Regex:    regex,
+		Replace:  ruleConfig.Replace,
+		Classify: ruleConfig.Classify,
+	}, nil
+}
+
+// Rewrite runs the configured rules over a YouTube title and artist.
+func (s *Service) Rewrite(title string, artist string) RewriteResult {
+	return applyRewriteRules(s.RewriteRules, title, artist)
+}
+
+// PreviewRewrite runs rules, or the configured rules when none are given,
+// over a title and artist without touching any track, so rules can be tuned
+// before they go into the config.
+func (s *Service) PreviewRewrite(title string, artist string, ruleConfigs []config.RewriteRule) (RewriteResult, error) {
+	if len(ruleConfigs) == 0 {
+		return s.Rewrite(title, artist), nil
+	}
+	rules := make([]RewriteRule, 0, len(ruleConfigs))
+	for _, ruleConfig := range ruleConfigs {
+		rule, err := compileRewriteRule(ruleConfig)
+		if err != nil {
+			return RewriteResult{}, err
+		}
+		rules = append(rules, rule)
+	}
+	return applyRewriteRules(rules, title, artist), nil
+}
+
+func applyRewriteRules(rules []RewriteRule, title string, artist string) RewriteResult {
+	result := RewriteResult{Title: title, Artist: artist, Classes: make([]string, 0), Applied: make([]AppliedRule, 0)}
+	for _, rule := range rules {
+		field := &result.Title
+		if rule.Field == RewriteFieldArtist {
+			field = &result.Artist
+		}
+		match := rule.Regex.FindStringSubmatch(*field)
+		if match == nil {
+			continue
+		}
+		applied := AppliedRule{Name: rule.Name, Field: rule.Field, Before: *field, After: *field, Classify: rule.Classify}
+		if rule.Classify != "" {
+			result.addClass(rule.Classify)
+			if index := rule.Regex.SubexpIndex(coverArtistGroup); rule.Classify == ClassCover && index >= 0 && result.CoverArtist == "" {
+				result.CoverArtist = strings.TrimSpace(match[index])
+			}
+		}
+		if rule.Replace != nil {
+			applied.After = strings.Join(strings.Fields(rule.Regex.ReplaceAllString(*field, *rule.Replace)), " ")
+			*field = applied.After
+		}
+		result.Applied = append(result.Applied, applied)
+	}
+	return result
+}
+
+func (r *RewriteResult) addClass(class string) {
+	for _, existing := range r.Classes {
+		if existing == class {
+			return
+		}
+	}
+	r.Classes = append(r.Classes, class)
+}
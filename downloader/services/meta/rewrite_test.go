@@ -0,0 +1,85 @@
+package meta
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gcottom/yt-dl-services/downloader/config"
+)
+
+func defaultRewriteService() *Service {
+	s := &Service{Config: &config.Config{}}
+	s.RewriteRules = s.buildRewriteRules()
+	return s
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name            string
+		title           string
+		artist          string
+		wantTitle       string
+		wantArtist      string
+		wantCoverArtist string
+		wantClasses     []string
+	}{
+		{"drops brackets", "Song (Official Video) [HD]", "Artist", "Song", "Artist", "", []string{}},
+		{"keeps remix", "Song (Artist Remix)", "Artist", "Song Artist Remix", "Artist", "", []string{ClassRemix}},
+		{"keeps live", "Song [Live at Wembley]", "Artist", "Song Live at Wembley", "Artist", "", []string{ClassLive}},
+		{"classifies live at", "Song - Live at Wembley", "Artist", "Song - Live at Wembley", "Artist", "", []string{ClassLive}},
+		{"keeps instrumental", "Song (Instrumental)", "Artist", "Song Instrumental", "Artist", "", []string{ClassInstrumental}},
+		{"keeps acoustic", "Song (Acoustic Version)", "Artist", "Song Acoustic Version", "Artist", "", []string{}},
+		{"cover by", "Song (Cover by Someone)", "Channel", "Song", "Channel", "Someone", []string{ClassCover}},
+		{"artist cover", "Song 【Someone Cover】", "Channel", "Song", "Channel", "Someone", []string{ClassCover}},
+		{"full-width brackets", "Song （Official Audio）", "Artist", "Song", "Artist", "", []string{}},
+		{"topic channel", "Song", "Artist - Topic", "Song", "Artist", "", []string{}},
+		{"vevo channel", "Song", "ArtistVEVO", "Song", "Artist", "", []string{}},
+		{"official channel", "Song", "Artist Official", "Song", "Artist", "", []string{}},
+		{"handle", "Song", "@artist", "Song", "artist", "", []string{}},
+		{"word starting with topic", "Song", "The Topicality", "Song", "The Topicality", "", []string{}},
+		{"word starting with vevo", "Song", "VEVOlution", "Song", "VEVOlution", "", []string{}},
+		{"word starting with official", "Song", "Officially Yours", "Song", "Officially Yours", "", []string{}},
+	}
+	s := defaultRewriteService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Rewrite(tt.title, tt.artist)
+			if got.Title != tt.wantTitle || got.Artist != tt.wantArtist || got.CoverArtist != tt.wantCoverArtist {
+				t.Errorf("Rewrite(%q, %q) = %q, %q, cover artist %q, want %q, %q, cover artist %q", tt.title, tt.artist, got.Title, got.Artist, got.CoverArtist, tt.wantTitle, tt.wantArtist, tt.wantCoverArtist)
+			}
+			if !reflect.DeepEqual(got.Classes, tt.wantClasses) {
+				t.Errorf("Rewrite(%q, %q) classes = %v, want %v", tt.title, tt.artist, got.Classes, tt.wantClasses)
+			}
+		})
+	}
+}
+
+func TestPreviewRewrite(t *testing.T) {
+	s := defaultRewriteService()
+	tests := []struct {
+		name       string
+		rules      []config.RewriteRule
+		wantTitle  string
+		wantArtist string
+		wantErr    error
+	}{
+		{"configured rules", nil, "Song", "Artist", nil},
+		{"given rules", []config.RewriteRule{{Name: "strip hd", Field: RewriteFieldTitle, Match: `\s*\[HD\]`, Replace: replaceWith("")}}, "Song (Official Video)", "Artist - Topic", nil},
+		{"classify only", []config.RewriteRule{{Name: "live", Field: RewriteFieldTitle, Match: `(?i)live`, Classify: ClassLive}}, "Song (Official Video) [HD]", "Artist - Topic", nil},
+		{"unknown field", []config.RewriteRule{{Name: "bad", Field: "album", Match: `x`}}, "", "", ErrInvalidRewriteRule},
+		{"unknown class", []config.RewriteRule{{Name: "bad", Field: RewriteFieldTitle, Match: `x`, Classify: "remaster"}}, "", "", ErrInvalidRewriteRule},
+		{"bad regex", []config.RewriteRule{{Name: "bad", Field: RewriteFieldTitle, Match: `(`}}, "", "", ErrInvalidRewriteRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.PreviewRewrite("Song (Official Video) [HD]", "Artist - Topic", tt.rules)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PreviewRewrite() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Title != tt.wantTitle || got.Artist != tt.wantArtist {
+				t.Errorf("PreviewRewrite() = %q, %q, want %q, %q", got.Title, got.Artist, tt.wantTitle, tt.wantArtist)
+			}
+		})
+	}
+}
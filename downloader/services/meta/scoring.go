@@ -0,0 +1,155 @@
+package meta
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+const (
+	// defaultMatchThreshold is the score a candidate needs to be used when
+	// matching.threshold is not set.
+	defaultMatchThreshold = 0.7
+	// defaultDurationTolerance is the difference in seconds at which the
+	// duration score halves when matching.durationTolerance is not set.
+	defaultDurationTolerance = 10
+
+	titleWeight    = 0.45
+	artistWeight   = 0.35
+	durationWeight = 0.2
+	versionPenalty = 0.15
+	// maxTitleParts caps how many separated parts of a YouTube title are
+	// combined into match variants.
+	maxTitleParts = 6
+)
+
+var titleSeparatorRegex = regexp.MustCompile(`[-:|–—~]`)
+
+var versionKeywords = []string{"remix", "live", "acoustic", "instrumental", "karaoke", "extended", "radio edit", "sped up", "slowed", "demo", "unplugged"}
+
+var versionKeywordRegexes = func() map[string]*regexp.Regexp {
+	regexes := make(map[string]*regexp.Regexp)
+	for _, keyword := range versionKeywords {
+		regexes[keyword] = regexp.MustCompile(`\b` + keyword + `\b`)
+	}
+	return regexes
+}()
+
+// MatchThreshold returns the score a candidate needs to be used.
+func (s *Service) MatchThreshold() float64 {
+	if s.Config.Matching.Threshold <= 0 {
+		return defaultMatchThreshold
+	}
+	return s.Config.Matching.Threshold
+}
+
+// durationTolerance returns the configured duration tolerance in seconds.
+func (s *Service) durationTolerance() int {
+	if s.Config.Matching.DurationTolerance <= 0 {
+		return defaultDurationTolerance
+	}
+	return s.Config.Matching.DurationTolerance
+}
+
+// scoreCandidate rates a search result against the title and artist variants
+// generated from the YouTube metadata. ytDuration and the candidate duration
+// are both in milliseconds.
+func (s *Service) scoreCandidate(titles []string, artists []string, ytTitle string, ytDuration int, candidate TrackMeta) CandidateScore {
+	score := CandidateScore{Meta: candidate}
+	for _, title := range titles {
+		score.TitleScore = math.Max(score.TitleScore, Similarity(title, candidate.Title))
+	}
+	candidateArtists := append([]string{candidate.Artist}, strings.Split(candidate.Artist, ", ")...)
+	candidateArtists = append(candidateArtists, candidate.Artists...)
+	for _, artist := range artists {
+		for _, candidateArtist := range candidateArtists {
+			score.ArtistScore = math.Max(score.ArtistScore, Similarity(artist, candidateArtist))
+		}
+	}
+	score.DurationScore = s.durationScore(ytDuration, candidate.Duration)
+	score.VersionPenalty = versionMismatchPenalty(ytTitle, candidate.Title+" "+candidate.Album)
+	score.Score = titleWeight*score.TitleScore + artistWeight*score.ArtistScore + durationWeight*score.DurationScore - score.VersionPenalty
+	if score.Rejected = studioMismatch(ytTitle, candidate.Title+" "+candidate.Album+" "+candidate.Version); score.Rejected != "" {
+		score.Score = 0
+	}
+	return score
+}
+
+// durationScore is 1 for identical lengths, falls to 0.5 at the configured
+// tolerance and reaches 0 at four times the tolerance. Unknown lengths score
+// 0.5 so they neither help nor sink a candidate.
+func (s *Service) durationScore(a, b int) float64 {
+	if a <= 0 || b <= 0 {
+		return 0.5
+	}
+	tolerance := float64(s.durationTolerance())
+	delta := math.Abs(float64(a-b)) / 1000
+	if delta <= tolerance {
+		return 1 - 0.5*delta/tolerance
+	}
+	return math.Max(0, 0.5-0.5*(delta-tolerance)/(3*tolerance))
+}
+
+// versionMismatchPenalty penalises every version keyword that appears on only
+// one side, so a radio edit is not swapped for an extended mix.
+func versionMismatchPenalty(ytTitle string, candidateText string) float64 {
+	ytTitle = strings.ToLower(ytTitle)
+	candidateText = strings.ToLower(candidateText)
+	penalty := 0.0
+	for _, keyword := range versionKeywords {
+		regex := versionKeywordRegexes[keyword]
+		if regex.MatchString(ytTitle) != regex.MatchString(candidateText) {
+			penalty += versionPenalty
+		}
+	}
+	return penalty
+}
+
+// matchVariants generates the titles and artists a YouTube title may contain.
+// Uploads are usually "Artist - Title", but the separator and the number of
+// parts vary, so every contiguous run of parts is tried as both.
+func (s *Service) matchVariants(trackMeta TrackMeta, sanitizedTitle string, featStrippedTitle string, coverArtist string) ([]string, []string) {
+	titles := []string{trackMeta.Title, sanitizedTitle, featStrippedTitle}
+	artists := []string{trackMeta.Artist, s.SanitizeAuthor(trackMeta.Artist)}
+	if coverArtist != "" {
+		artists = append(artists, s.SanitizeAuthor(coverArtist))
+	}
+	for _, title := range []string{sanitizedTitle, featStrippedTitle} {
+		parts := titleSeparatorRegex.Split(title, -1)
+		if len(parts) > maxTitleParts {
+			parts = parts[:maxTitleParts]
+		}
+		for _, run := range contiguousRuns(parts) {
+			titles = append(titles, run)
+			if len(parts) > 1 {
+				artists = append(artists, s.SanitizeAuthor(run))
+			}
+		}
+	}
+	return uniqueNonEmpty(titles), uniqueNonEmpty(artists)
+}
+
+// contiguousRuns returns every run of adjacent parts joined with a space.
+func contiguousRuns(parts []string) []string {
+	runs := make([]string, 0)
+	for start := range parts {
+		for end := start + 1; end <= len(parts); end++ {
+			runs = append(runs, strings.Join(parts[start:end], " "))
+		}
+	}
+	return runs
+}
+
+func uniqueNonEmpty(strs []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(strs))
+	for _, str := range strs {
+		str = strings.Join(strings.Fields(str), " ")
+		if str == "" || seen[str] {
+			continue
+		}
+		seen[str] = true
+		out = append(out, str)
+	}
+	return out
+}
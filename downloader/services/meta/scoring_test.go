@@ -0,0 +1,97 @@
+package meta
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gcottom/yt-dl-services/downloader/config"
+)
+
+func TestScoreCandidate(t *testing.T) {
+	service := &Service{Config: &config.Config{}}
+	titles := []string{"Bohemian Rhapsody"}
+	artists := []string{"Queen"}
+	tests := []struct {
+		name       string
+		ytTitle    string
+		ytDuration int
+		candidate  TrackMeta
+		min        float64
+		max        float64
+	}{
+		{"exact match", "Queen - Bohemian Rhapsody", 354000, TrackMeta{Title: "Bohemian Rhapsody", Artist: "Queen", Duration: 354000}, 1, 1},
+		{"unknown duration", "Queen - Bohemian Rhapsody", 0, TrackMeta{Title: "Bohemian Rhapsody", Artist: "Queen", Duration: 354000}, 0.9, 0.9},
+		{"duration far off", "Queen - Bohemian Rhapsody", 354000, TrackMeta{Title: "Bohemian Rhapsody", Artist: "Queen", Duration: 120000}, 0.8, 0.8},
+		{"other artist", "Queen - Bohemian Rhapsody", 354000, TrackMeta{Title: "Bohemian Rhapsody", Artist: "Panic! At The Disco", Duration: 354000}, 0.65, 0.75},
+		{"other title", "Queen - Bohemian Rhapsody", 354000, TrackMeta{Title: "Radio Ga Ga", Artist: "Queen", Duration: 354000}, 0.55, 0.7},
+		{"matches one of several artists", "Queen - Bohemian Rhapsody", 354000, TrackMeta{Title: "Bohemian Rhapsody", Artist: "Queen, David Bowie", Duration: 354000}, 1, 1},
+		{"version only on the candidate", "Queen - Bohemian Rhapsody", 354000, TrackMeta{Title: "Bohemian Rhapsody - Remix", Artist: "Queen", Duration: 354000}, 0.7, 0.85},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := service.scoreCandidate(titles, artists, tt.ytTitle, tt.ytDuration, tt.candidate)
+			if got.Score < tt.min-1e-9 || got.Score > tt.max+1e-9 {
+				t.Errorf("scoreCandidate(%+v) = %.3f, want between %.2f and %.2f", tt.candidate, got.Score, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDurationScore(t *testing.T) {
+	service := &Service{Config: &config.Config{}}
+	tests := []struct {
+		name string
+		a    int
+		b    int
+		want float64
+	}{
+		{"identical", 200000, 200000, 1},
+		{"half the tolerance", 200000, 205000, 0.75},
+		{"at the tolerance", 200000, 210000, 0.5},
+		{"twice the tolerance", 200000, 180000, 0.5 - 0.5/3},
+		{"four times the tolerance", 200000, 240000, 0},
+		{"far beyond the tolerance", 200000, 600000, 0},
+		{"unknown length", 0, 200000, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.durationScore(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("durationScore(%d, %d) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionMismatchPenalty(t *testing.T) {
+	tests := []struct {
+		name      string
+		ytTitle   string
+		candidate string
+		want      float64
+	}{
+		{"no versions", "Song", "Song Album", 0},
+		{"same version", "Song (Live)", "Song - Live Album", 0},
+		{"version only on youtube", "Song (Acoustic)", "Song Album", versionPenalty},
+		{"version only on the candidate", "Song", "Song - Radio Edit Album", versionPenalty},
+		{"different versions", "Song (Extended Mix)", "Song (Remix) Album", 2 * versionPenalty},
+		{"keyword inside a word", "Oliver Song", "Song Album", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionMismatchPenalty(tt.ytTitle, tt.candidate); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("versionMismatchPenalty(%q, %q) = %.2f, want %.2f", tt.ytTitle, tt.candidate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchThreshold(t *testing.T) {
+	service := &Service{Config: &config.Config{}}
+	if got := service.MatchThreshold(); got != defaultMatchThreshold {
+		t.Errorf("MatchThreshold() without a matching block = %.2f, want %.2f", got, defaultMatchThreshold)
+	}
+	service.Config.Matching.Threshold = 0.9
+	if got := service.MatchThreshold(); got != 0.9 {
+		t.Errorf("MatchThreshold() = %.2f, want 0.90", got)
+	}
+}
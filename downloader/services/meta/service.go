@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"image"
+	"math"
 	"net/http"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/gcottom/go-zaplog"
@@ -15,60 +16,196 @@ import (
 	"github.com/gcottom/retry"
 	"github.com/gcottom/yt-dl-services/downloader/track_sql"
 	"github.com/zmb3/spotify/v2"
-	spotifyauth "github.com/zmb3/spotify/v2/auth"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
+	"golang.org/x/text/unicode/norm"
 )
 
 func (s *Service) SaveMeta(ctx context.Context, data []byte, trackData track_sql.Track) ([]byte, TrackMeta, error) {
-	tag, err := mp3meta.ParseMP3(bytes.NewReader(data))
+	resolution, err := s.ResolveMeta(ctx, trackData)
+	if err != nil {
+		return nil, TrackMeta{}, err
+	}
+	data, err = s.ApplyMeta(ctx, data, trackData, resolution.Best)
 	if err != nil {
-		zaplog.ErrorC(ctx, "failed to read mp3", zap.Error(err))
 		return nil, TrackMeta{}, err
 	}
+	return data, resolution.Best, nil
+}
+
+// ResolveMeta looks the track up with every metadata provider and picks the
+// best match without touching the audio, so low-confidence matches can be
+// reviewed before anything is written.
+func (s *Service) ResolveMeta(ctx context.Context, trackData track_sql.Track) (MetaResolution, error) {
 	res, err := retry.Retry(retry.NewAlgSimpleDefault(), 5, s.GetYTMetaFromID, ctx, trackData)
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to get yt meta", zap.Error(err))
-		return nil, TrackMeta{}, err
+		return MetaResolution{}, err
 	}
 	trackMeta := res[0].(TrackMeta)
-	res, err = retry.Retry(retry.NewAlgSimpleDefault(), 5, s.GetSpotifyMeta, ctx, trackMeta)
-	if err != nil {
-		zaplog.ErrorC(ctx, "failed to get spotify meta", zap.Error(err))
-		return nil, TrackMeta{}, err
+	if trackMeta.Duration == 0 {
+		trackMeta.Duration = trackData.Duration
 	}
-	spotifyMetas := res[0].([]TrackMeta)
-	bestMeta := s.GetBestMetaMatch(ctx, trackMeta, spotifyMetas)
+	ranked := s.RankCandidates(ctx, trackMeta, s.SearchProviders(ctx, trackMeta, trackData))
+	bestMeta := s.CreditArtists(s.ApplyVersion(trackMeta.Title, s.bestRankedMatch(ctx, trackMeta, ranked)))
 	zaplog.InfoC(ctx, "best meta match", zap.String("title", bestMeta.Title), zap.String("artist", bestMeta.Artist))
+	return MetaResolution{YTMeta: trackMeta, Best: bestMeta, Candidates: ranked}, nil
+}
 
+// ApplyMeta writes trackMeta into the ID3 tag of the mp3 in data. The genre
+// comes from trackData unless trackMeta sets one.
+func (s *Service) ApplyMeta(ctx context.Context, data []byte, trackData track_sql.Track, bestMeta TrackMeta) ([]byte, error) {
+	tag, err := mp3meta.ParseMP3(bytes.NewReader(data))
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to read mp3", zap.Error(err))
+		return nil, err
+	}
 	tag.SetTitle(bestMeta.Title)
 	tag.SetArtist(bestMeta.Artist)
 	tag.SetAlbum(bestMeta.Album)
-	tag.SetGenre(trackData.Genre)
-	if bestMeta.CoverArtURL != "" {
-		response, err := http.Get(bestMeta.CoverArtURL)
+	tag.SetAlbumArtist(bestMeta.AlbumArtist)
+	genre := trackData.Genre
+	if bestMeta.Genre != "" {
+		genre = bestMeta.Genre
+	}
+	tag.SetGenre(genre)
+	tag.SetTrackNumber(bestMeta.TrackNumber)
+	tag.SetDiscNumber(bestMeta.DiscNumber)
+	if bestMeta.Year > 0 {
+		tag.SetYear(bestMeta.Year)
+	}
+	tag.SetISRC(bestMeta.ISRC)
+	tag.SetPublisher(bestMeta.Label)
+	tag.SetCopyright(bestMeta.Copyright)
+	tag.SetComposer(bestMeta.Composer)
+	tag.SetLyricist(bestMeta.Lyricist)
+	// TLEN is the length of the audio in the file, which the matched
+	// release only approximates.
+	length := trackData.Duration
+	if length <= 0 {
+		length = bestMeta.Duration
+	}
+	if length > 0 {
+		tag.SetLength(fmt.Sprint(length))
+	}
+	output := new(bytes.Buffer)
+	if err := tag.Save(output); err != nil {
+		zaplog.ErrorC(ctx, "failed to save tag", zap.Error(err))
+		return nil, err
+	}
+	extra := ExtraFrames{
+		UserText:       map[string]string{"RELEASEDATE": bestMeta.ReleaseDate},
+		TextValues:     make(map[string][]string),
+		UserTextValues: map[string][]string{"ARTISTS": append(append([]string{}, bestMeta.Artists...), bestMeta.FeaturedArtists...)},
+		UniqueFileIDs:  make(map[string]string),
+	}
+	// Without a structured credit the single artist string is kept.
+	if performers := s.performers(bestMeta); len(performers) > 0 {
+		extra.TextValues["TPE1"] = performers
+	}
+	if bestMeta.Explicit {
+		extra.UserText["ITUNESADVISORY"] = "1"
+	}
+	extra.UserText[frameVersion] = bestMeta.Version
+	extra.TextValues["TIT3"] = nil
+	if bestMeta.Version != "" {
+		extra.TextValues["TIT3"] = []string{bestMeta.Version}
+	}
+	addMusicBrainzFrames(bestMeta, extra)
+	addProvenanceFrames(data, trackData, bestMeta, &extra)
+	addLyricsFrames(bestMeta, &extra)
+	// Missing cover art is not worth failing the track over.
+	if len(bestMeta.CoverArt) > 0 {
+		extra.CoverArt = bestMeta.CoverArt
+	} else if bestMeta.CoverArtURL != "" {
+		coverArt, err := s.GetCoverArt(ctx, bestMeta.CoverArtURL)
 		if err != nil {
-			zaplog.ErrorC(ctx, "failed to get cover art", zap.Error(err))
-			return nil, TrackMeta{}, err
+			zaplog.WarnC(ctx, "cover art not available", zap.String("url", bestMeta.CoverArtURL), zap.Error(err))
+		} else {
+			extra.CoverArt = coverArt
 		}
-		defer response.Body.Close()
-		img, _, err := image.Decode(response.Body)
+	}
+	data, err = writeExtraFrames(output.Bytes(), extra)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to write extra frames", zap.Error(err))
+		return nil, err
+	}
+	return data, nil
+}
+
+// ApplyOverride changes only the fields set in overrides in the existing tag
+// of data, keeping everything else the file was tagged with. The returned
+// TrackMeta holds the fields of the new tag that files are named by.
+func (s *Service) ApplyOverride(ctx context.Context, data []byte, overrides TrackMeta) ([]byte, TrackMeta, error) {
+	coverArt, err := frontCover(data)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to read cover art", zap.Error(err))
+		return nil, TrackMeta{}, err
+	}
+	if len(overrides.CoverArt) > 0 {
+		coverArt = overrides.CoverArt
+	} else if overrides.CoverArtURL != "" {
+		coverArt, err = s.GetCoverArt(ctx, overrides.CoverArtURL)
 		if err != nil {
-			zaplog.ErrorC(ctx, "failed to decode cover art", zap.Error(err))
+			zaplog.ErrorC(ctx, "failed to get cover art", zap.String("url", overrides.CoverArtURL), zap.Error(err))
 			return nil, TrackMeta{}, err
 		}
-		tag.SetCoverArt(&img)
+	}
+	tag, err := mp3meta.ParseMP3(bytes.NewReader(data))
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to read mp3", zap.Error(err))
+		return nil, TrackMeta{}, err
+	}
+	if overrides.Title != "" {
+		tag.SetTitle(overrides.Title)
+	}
+	extra := ExtraFrames{CoverArt: coverArt}
+	if overrides.Artist != "" {
+		tag.SetArtist(overrides.Artist)
+		// The structured credit no longer matches the artist.
+		extra.UserTextValues = map[string][]string{"ARTISTS": nil}
+	}
+	if overrides.Album != "" {
+		tag.SetAlbum(overrides.Album)
+	}
+	if overrides.Genre != "" {
+		tag.SetGenre(overrides.Genre)
+	}
+	if overrides.Year > 0 {
+		tag.SetYear(overrides.Year)
+	}
+	result := TrackMeta{
+		Title:       tag.GetTitle(),
+		Artist:      displayTextValues(id3Version(data), tag.GetArtist()),
+		Album:       tag.GetAlbum(),
+		AlbumArtist: tag.GetAlbumArtist(),
+		Genre:       tag.GetGenre(),
+		Version:     tag.GetSubTitle(),
+		TrackNumber: tag.GetTrackNumber(),
+		DiscNumber:  tag.GetDiscNumber(),
+		Year:        tag.GetYear(),
+		ISRC:        tag.GetISRC(),
+	}
+	if overrides.Artist != "" {
+		result.Artist = overrides.Artist
 	}
 	output := new(bytes.Buffer)
 	if err := tag.Save(output); err != nil {
 		zaplog.ErrorC(ctx, "failed to save tag", zap.Error(err))
 		return nil, TrackMeta{}, err
 	}
-	return output.Bytes(), bestMeta, nil
+	// mp3meta re-encodes the cover at its default quality, so the original
+	// picture is written back as it was.
+	data, err = writeExtraFrames(output.Bytes(), extra)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to write extra frames", zap.Error(err))
+		return nil, TrackMeta{}, err
+	}
+	return data, result, nil
 }
 
 func (s *Service) GetYTMetaFromID(ctx context.Context, trackData track_sql.Track) (TrackMeta, error) {
-	req, err := s.HTTPClient.CreateRequest(http.MethodGet, fmt.Sprintf("http://music-api:%d%s?id=%s", s.Config.Ports.MusicAPI, s.Config.Endpoints.Meta, trackData.ID), nil)
+	req, err := s.HTTPClient.CreateRequest(http.MethodGet, fmt.Sprintf("http://music-api:%d%s?id=%s", s.Config.Ports.MusicAPI, s.Config.Endpoints.Meta, trackData.SourceVideoID()), nil)
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to create meta request", zap.Error(err))
 		return TrackMeta{}, err
@@ -83,24 +220,70 @@ func (s *Service) GetYTMetaFromID(ctx context.Context, trackData track_sql.Track
 		zaplog.ErrorC(ctx, "failed to unmarshal meta response", zap.Error(err))
 		return TrackMeta{}, err
 	}
-	outmeta := TrackMeta{Artist: meta.Author, Title: meta.Title, CoverArtURL: meta.Image}
+	outmeta := TrackMeta{Artist: meta.Author, Title: meta.Title, CoverArtURL: meta.Image, Duration: meta.Duration * 1000}
 	return outmeta, nil
 }
 
+// FindATVVersion looks up the "Art Track" upload of the song in a music video.
+// Art tracks carry the album audio without intros or skits. An empty ID is
+// returned when id is not a music video or no art track matches it.
+func (s *Service) FindATVVersion(ctx context.Context, id string) (string, error) {
+	req, err := s.HTTPClient.CreateRequest(http.MethodGet, fmt.Sprintf("http://music-api:%d%s?id=%s", s.Config.Ports.MusicAPI, s.Config.Endpoints.ATV, id), nil)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to create atv request", zap.Error(err))
+		return "", err
+	}
+	res, status, err := s.HTTPClient.DoRequest(req)
+	if err != nil {
+		zaplog.ErrorC(ctx, "error while sending atv request", zap.Error(err))
+		return "", err
+	}
+	if status != http.StatusOK {
+		zaplog.ErrorC(ctx, "atv request failed", zap.Int("status", status))
+		return "", fmt.Errorf("atv request failed with status %d", status)
+	}
+	var atv ATVResponse
+	if err = json.Unmarshal(res, &atv); err != nil {
+		zaplog.ErrorC(ctx, "failed to unmarshal atv response", zap.Error(err))
+		return "", err
+	}
+	if atv.Type != "omv" {
+		return "", nil
+	}
+
+	sanitizedTitle := s.SanitizeString(s.SanitizeParenthesis(atv.Title))
+	titles, artists := s.matchVariants(TrackMeta{Title: atv.Title, Artist: atv.Author}, sanitizedTitle, sanitizedTitle, "")
+	var best *CandidateScore
+	bestID := ""
+	for _, candidate := range atv.Candidates {
+		score := s.scoreCandidate(titles, artists, atv.Title, atv.Duration*1000, TrackMeta{Title: candidate.Title, Artist: candidate.Author, Duration: candidate.Duration * 1000})
+		if score.VersionPenalty > 0 {
+			continue
+		}
+		if best == nil || score.Score > best.Score {
+			best = &score
+			bestID = candidate.ID
+		}
+	}
+	if best == nil || best.Score < s.MatchThreshold() {
+		zaplog.InfoC(ctx, "no matching atv version found", zap.String("id", id))
+		return "", nil
+	}
+	zaplog.InfoC(ctx, "found atv version", zap.String("id", id), zap.String("atvID", bestID), zap.Float64("score", best.Score))
+	return bestID, nil
+}
+
 func (s *Service) GetSpotifyMeta(ctx context.Context, trackMeta TrackMeta) ([]TrackMeta, error) {
 	searchTerm := fmt.Sprintf("track:%s artist:%s", trackMeta.Title, trackMeta.Artist)
 	zaplog.InfoC(ctx, "searching spotify", zap.String("searchTerm", searchTerm))
 
-	token, err := s.GetSpotifyToken(ctx)
-	if err != nil {
-		zaplog.ErrorC(ctx, "failed to get spotify token", zap.Error(err))
-		return nil, err
+	cacheKey := searchCacheKey("spotify", trackMeta.Title, trackMeta.Artist)
+	if cached, ok := s.cachedSearch(ctx, cacheKey); ok {
+		zaplog.InfoC(ctx, "spotify search cache hit", zap.String("searchTerm", searchTerm))
+		return cached, nil
 	}
 
-	authClient := spotifyauth.New().Client(ctx, token)
-	spotifyClient := spotify.New(authClient)
-
-	res, err := spotifyClient.Search(ctx, searchTerm, spotify.SearchTypeTrack)
+	res, err := s.SpotifyClient.Search(ctx, searchTerm, spotify.SearchTypeTrack)
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to search spotify", zap.Error(err))
 		return nil, err
@@ -118,17 +301,34 @@ func (s *Service) GetSpotifyMeta(ctx context.Context, trackMeta TrackMeta) ([]Tr
 			artists = append(artists, artist.Name)
 		}
 
+		albumArtists := make([]string, 0)
+		for _, artist := range track.Album.Artists {
+			albumArtists = append(albumArtists, artist.Name)
+		}
+
 		resMeta.Artist = strings.Join(artists, ", ")
+		resMeta.Artists = artists
+		resMeta.AlbumArtist = strings.Join(albumArtists, ", ")
 		resMeta.Album = track.Album.Name
 		resMeta.Title = track.Name
+		resMeta.TrackNumber = int(track.TrackNumber)
+		resMeta.DiscNumber = int(track.DiscNumber)
+		resMeta.ReleaseDate = track.Album.ReleaseDate
+		if track.Album.ReleaseDate != "" {
+			resMeta.Year = track.Album.ReleaseDateTime().Year()
+		}
+		resMeta.ISRC = track.ExternalIDs["isrc"]
+		resMeta.Duration = int(track.Duration)
+		resMeta.Explicit = track.Explicit
 		trackMetas = append(trackMetas, resMeta)
 	}
 
 	zaplog.InfoC(ctx, "spotify search results", zap.Any("results", trackMetas))
+	s.cacheSearch(ctx, cacheKey, trackMetas)
 	return trackMetas, nil
 }
 func (s *Service) GetSpotifyToken(ctx context.Context) (*oauth2.Token, error) {
-	token, err := s.SpotifyConfig.Token(ctx)
+	token, err := s.SpotifyTokenSource.Token()
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to get spotify token", zap.Error(err))
 		return nil, err
@@ -136,141 +336,159 @@ func (s *Service) GetSpotifyToken(ctx context.Context) (*oauth2.Token, error) {
 	return token, nil
 }
 
-func (s *Service) GetBestMetaMatch(ctx context.Context, trackMeta TrackMeta, spotifyMetas []TrackMeta) TrackMeta {
-	coverArtist := s.CoverArtistCheck(ctx, trackMeta.Title)
-	if coverArtist != "" {
-		zaplog.InfoC(ctx, "cover artist found", zap.String("coverArtist", coverArtist))
+// GetBestMetaMatch scores every candidate against the YouTube metadata and
+// returns the most trusted one that clears the match threshold, with missing
+// fields filled from the other accepted candidates. When nothing matches, the
+// sanitized YouTube title is used instead.
+func (s *Service) GetBestMetaMatch(ctx context.Context, trackMeta TrackMeta, candidates []TrackMeta) TrackMeta {
+	return s.bestRankedMatch(ctx, trackMeta, s.RankCandidates(ctx, trackMeta, candidates))
+}
+
+// bestRankedMatch is GetBestMetaMatch for candidates already ranked by
+// RankCandidates.
+func (s *Service) bestRankedMatch(ctx context.Context, trackMeta TrackMeta, ranked []CandidateScore) TrackMeta {
+	index, selection := s.chooseCandidate(ranked)
+	if index < 0 {
+		zaplog.InfoC(ctx, "no candidate above match threshold", zap.Float64("threshold", s.MatchThreshold()))
+		return s.fallbackMeta(trackMeta)
 	}
-	sanitizedTitle := s.SanitizeString(s.SanitizeParenthesis(trackMeta.Title))
-	zaplog.InfoC(ctx, "sanitized title", zap.String("title", sanitizedTitle))
-	featStrippedTitle := strings.Split(sanitizedTitle, "feat")[0]
-	zaplog.InfoC(ctx, "feat stripped title", zap.String("title", featStrippedTitle))
-	titles := []string{trackMeta.Title, sanitizedTitle, featStrippedTitle}
-	artists := []string{trackMeta.Artist}
-	if coverArtist != "" {
-		artists = append(artists, s.SanitizeAuthor(coverArtist))
+	best := ranked[index]
+	zaplog.InfoC(ctx, "best candidate", zap.String("provider", best.Meta.Provider), zap.Float64("score", best.Score), zap.String("selection", selection))
+	accepted := []CandidateScore{best}
+	for i, score := range ranked {
+		if i != index && score.Score >= s.MatchThreshold() {
+			accepted = append(accepted, score)
+		}
 	}
-	if len(spotifyMetas) == 0 {
-		spotifyMetas, err := s.GetSpotifyMeta(ctx, TrackMeta{Title: sanitizedTitle, Artist: trackMeta.Artist})
-		if err != nil {
-			zaplog.ErrorC(ctx, "failed to get spotify meta", zap.Error(err))
-			return TrackMeta{Title: sanitizedTitle, Artist: trackMeta.Artist, Album: sanitizedTitle, Genre: trackMeta.Genre, CoverArtURL: trackMeta.CoverArtURL}
+	match := best.Meta
+	match.Genre = trackMeta.Genre
+	match.MatchScore = best.Score
+	if selection == selectedByFingerprint {
+		// The audio identified the recording, however little the YouTube
+		// title resembles it.
+		match.MatchScore = math.Max(best.Score, best.Meta.FingerprintScore)
+	}
+	return mergeCandidates(match, accepted)
+}
+
+// How chooseCandidate picked a candidate.
+const (
+	selectedByScore       = "score"
+	selectedByTieBreak    = "fingerprint tie-break"
+	selectedByFingerprint = "fingerprint"
+)
+
+// fingerprintTieMargin is how close, in weighted score, an accepted candidate
+// whose recording the audio fingerprint identified must be to the best one to
+// be preferred over it.
+const fingerprintTieMargin = 0.05
+
+// chooseCandidate returns the index of the ranked candidate a track is
+// tagged with and how it was chosen, or -1 when none qualifies. The best
+// scoring candidate above the threshold wins, unless a close runner-up is
+// the recording AcoustID identified from the audio. When no candidate passes
+// the threshold, a confident AcoustID match is used instead.
+func (s *Service) chooseCandidate(ranked []CandidateScore) (int, string) {
+	identified := identifiedRecordings(ranked)
+	isIdentified := func(candidate CandidateScore) bool {
+		return candidate.Meta.FingerprintScore > 0 || identified[candidate.Meta.MusicBrainzRecordingID] > 0
+	}
+	weighted := func(candidate CandidateScore) float64 {
+		return candidate.Score * candidate.Meta.Confidence
+	}
+	best := -1
+	for i, candidate := range ranked {
+		if candidate.Score < s.MatchThreshold() {
+			continue
 		}
-		if coverArtist != "" {
-			caSpotifyMetas, err := s.GetSpotifyMeta(ctx, TrackMeta{Title: sanitizedTitle, Artist: coverArtist})
-			if err != nil {
-				zaplog.ErrorC(ctx, "failed to get spotify meta", zap.Error(err))
-				return TrackMeta{Title: sanitizedTitle, Artist: trackMeta.Artist, Album: sanitizedTitle, Genre: trackMeta.Genre, CoverArtURL: trackMeta.CoverArtURL}
+		if best < 0 {
+			best = i
+			if isIdentified(candidate) || len(identified) == 0 {
+				return best, selectedByScore
 			}
-			spotifyMetas = append(spotifyMetas, caSpotifyMetas...)
+			continue
+		}
+		if weighted(ranked[best])-weighted(candidate) > fingerprintTieMargin {
+			break
 		}
-		if len(spotifyMetas) == 0 {
-			return TrackMeta{Title: sanitizedTitle, Artist: trackMeta.Artist, Album: sanitizedTitle, Genre: trackMeta.Genre, CoverArtURL: trackMeta.CoverArtURL}
+		if isIdentified(candidate) {
+			return i, selectedByTieBreak
 		}
 	}
-	sanitizedSplits := strings.Split(strings.ReplaceAll(sanitizedTitle, ":", "-"), "-")
-	if len(sanitizedSplits) < 2 {
-		titles = append(titles, sanitizedSplits[0])
-	}
-	if len(sanitizedSplits) == 2 {
-		titles = append(titles, sanitizedSplits[0], sanitizedSplits[1])
-		artists = append(artists, s.SanitizeAuthor(sanitizedSplits[0]), s.SanitizeAuthor(sanitizedSplits[1]))
-	} else if len(sanitizedSplits) == 3 {
-		titles = append(titles, sanitizedSplits[0], sanitizedSplits[1], sanitizedSplits[2], fmt.Sprintf("%s %s", sanitizedSplits[0], sanitizedSplits[1]), fmt.Sprintf("%s %s", sanitizedSplits[1], sanitizedSplits[2]))
-		artists = append(artists, s.SanitizeAuthor(sanitizedSplits[0]), s.SanitizeAuthor(sanitizedSplits[1]), s.SanitizeAuthor(sanitizedSplits[2]), s.SanitizeAuthor(fmt.Sprintf("%s %s", sanitizedSplits[0], sanitizedSplits[1])), s.SanitizeAuthor(fmt.Sprintf("%s %s", sanitizedSplits[1], sanitizedSplits[2])))
-	} else if len(sanitizedSplits) == 4 {
-		titles = append(titles, sanitizedSplits[0], sanitizedSplits[1], sanitizedSplits[2], sanitizedSplits[3], fmt.Sprintf("%s %s", sanitizedSplits[0], sanitizedSplits[1]), fmt.Sprintf("%s %s", sanitizedSplits[1], sanitizedSplits[2]), fmt.Sprintf("%s %s", sanitizedSplits[2], sanitizedSplits[3]), fmt.Sprintf("%s %s %s", sanitizedSplits[0], sanitizedSplits[1], sanitizedSplits[2]), fmt.Sprintf("%s %s %s", sanitizedSplits[1], sanitizedSplits[2], sanitizedSplits[3]), fmt.Sprintf("%s %s", sanitizedSplits[0], sanitizedSplits[1]))
-		artists = append(artists, s.SanitizeAuthor(sanitizedSplits[0]), s.SanitizeAuthor(sanitizedSplits[1]), s.SanitizeAuthor(sanitizedSplits[2]), s.SanitizeAuthor(sanitizedSplits[3]), s.SanitizeAuthor(fmt.Sprintf("%s %s", sanitizedSplits[0], sanitizedSplits[1])), s.SanitizeAuthor(fmt.Sprintf("%s %s", sanitizedSplits[1], sanitizedSplits[2])), s.SanitizeAuthor(fmt.Sprintf("%s %s", sanitizedSplits[2], sanitizedSplits[3])), s.SanitizeAuthor(fmt.Sprintf("%s %s %s", sanitizedSplits[0], sanitizedSplits[1], sanitizedSplits[2])), s.SanitizeAuthor(fmt.Sprintf("%s %s %s", sanitizedSplits[1], sanitizedSplits[2], sanitizedSplits[3])), s.SanitizeAuthor(fmt.Sprintf("%s %s", sanitizedSplits[0], sanitizedSplits[1])))
-	}
-	featStrippedSplits := strings.Split(strings.ReplaceAll(featStrippedTitle, ":", "-"), "-")
-	if len(featStrippedSplits) < 2 {
-		titles = append(titles, featStrippedSplits[0])
-	}
-	if len(featStrippedSplits) == 2 {
-		titles = append(titles, featStrippedSplits[0], featStrippedSplits[1])
-		artists = append(artists, s.SanitizeAuthor(featStrippedSplits[0]), s.SanitizeAuthor(featStrippedSplits[1]))
-	} else if len(featStrippedSplits) == 3 {
-		titles = append(titles, featStrippedSplits[0], featStrippedSplits[1], featStrippedSplits[2], fmt.Sprintf("%s %s", featStrippedSplits[0], featStrippedSplits[1]), fmt.Sprintf("%s %s", featStrippedSplits[1], featStrippedSplits[2]))
-		artists = append(artists, s.SanitizeAuthor(featStrippedSplits[0]), s.SanitizeAuthor(featStrippedSplits[1]), s.SanitizeAuthor(featStrippedSplits[2]), s.SanitizeAuthor(fmt.Sprintf("%s %s", featStrippedSplits[0], featStrippedSplits[1])), s.SanitizeAuthor(fmt.Sprintf("%s %s", featStrippedSplits[1], featStrippedSplits[2])))
-	} else if len(featStrippedSplits) == 4 {
-		titles = append(titles, featStrippedSplits[0], featStrippedSplits[1], featStrippedSplits[2], featStrippedSplits[3], fmt.Sprintf("%s %s", featStrippedSplits[0], featStrippedSplits[1]), fmt.Sprintf("%s %s", featStrippedSplits[1], featStrippedSplits[2]), fmt.Sprintf("%s %s", featStrippedSplits[2], featStrippedSplits[3]), fmt.Sprintf("%s %s %s", featStrippedSplits[0], featStrippedSplits[1], featStrippedSplits[2]), fmt.Sprintf("%s %s %s", featStrippedSplits[1], featStrippedSplits[2], featStrippedSplits[3]), fmt.Sprintf("%s %s", featStrippedSplits[0], featStrippedSplits[1]))
-		artists = append(artists, s.SanitizeAuthor(featStrippedSplits[0]), s.SanitizeAuthor(featStrippedSplits[1]), s.SanitizeAuthor(featStrippedSplits[2]), s.SanitizeAuthor(featStrippedSplits[3]), s.SanitizeAuthor(fmt.Sprintf("%s %s", featStrippedSplits[0], featStrippedSplits[1])), s.SanitizeAuthor(fmt.Sprintf("%s %s", featStrippedSplits[1], featStrippedSplits[2])), s.SanitizeAuthor(fmt.Sprintf("%s %s", featStrippedSplits[2], featStrippedSplits[3])), s.SanitizeAuthor(fmt.Sprintf("%s %s %s", featStrippedSplits[0], featStrippedSplits[1], featStrippedSplits[2])), s.SanitizeAuthor(fmt.Sprintf("%s %s %s", featStrippedSplits[1], featStrippedSplits[2], featStrippedSplits[3])), s.SanitizeAuthor(fmt.Sprintf("%s %s", featStrippedSplits[0], featStrippedSplits[1])))
-	}
-	for i, title := range titles {
-		titles[i] = strings.Trim(strings.ReplaceAll(title, "  ", " "), " ")
-	}
-	for i, artist := range artists {
-		artists[i] = strings.Trim(strings.ReplaceAll(artist, "  ", " "), " ")
+	if best >= 0 {
+		return best, selectedByScore
 	}
-	zaplog.InfoC(ctx, "titles", zap.Strings("titles", titles))
-	zaplog.InfoC(ctx, "artists", zap.Strings("artists", artists))
-
-	for _, spotifyMeta := range spotifyMetas {
-		if coverArtist != "" {
-			if s.EqualIgnoringWhitespace(coverArtist, spotifyMeta.Artist) {
-				for _, title := range titles {
-					if s.EqualIgnoringWhitespace(title, spotifyMeta.Title) {
-						return TrackMeta{Title: spotifyMeta.Title, Artist: spotifyMeta.Artist, Album: spotifyMeta.Album, Genre: trackMeta.Genre, CoverArtURL: spotifyMeta.CoverArtURL}
-					}
-				}
-			}
-		}
-		for _, title := range titles {
-			if s.EqualIgnoringWhitespace(title, spotifyMeta.Title) {
-				for _, artist := range artists {
-					if s.EqualIgnoringWhitespace(artist, spotifyMeta.Artist) {
-						return TrackMeta{Title: spotifyMeta.Title, Artist: spotifyMeta.Artist, Album: spotifyMeta.Album, Genre: trackMeta.Genre, CoverArtURL: spotifyMeta.CoverArtURL}
-					}
-				}
-			}
+	minScore := s.Config.AcoustID.MinScore
+	if minScore <= 0 {
+		minScore = defaultAcoustIDMinScore
+	}
+	for i, candidate := range ranked {
+		if candidate.Rejected == "" && candidate.Meta.FingerprintScore >= minScore && (best < 0 || candidate.Meta.FingerprintScore > ranked[best].Meta.FingerprintScore) {
+			best = i
 		}
 	}
+	if best >= 0 {
+		return best, selectedByFingerprint
+	}
+	return -1, ""
+}
 
-	return TrackMeta{Title: sanitizedTitle, Artist: trackMeta.Artist, Album: "", Genre: trackMeta.Genre, CoverArtURL: trackMeta.CoverArtURL}
+// RankCandidates scores every candidate against the YouTube metadata and
+// sorts them by score weighted by provider confidence, best first.
+func (s *Service) RankCandidates(ctx context.Context, trackMeta TrackMeta, candidates []TrackMeta) []CandidateScore {
+	titles, artists, _ := s.variants(ctx, trackMeta)
+	scores := make([]CandidateScore, 0, len(candidates))
+	for _, candidate := range candidates {
+		score := s.scoreCandidate(titles, artists, trackMeta.Title, trackMeta.Duration, candidate)
+		zaplog.InfoC(ctx, "scored candidate", zap.String("provider", candidate.Provider), zap.String("title", candidate.Title), zap.String("artist", candidate.Artist), zap.Float64("score", score.Score))
+		scores = append(scores, score)
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score*scores[i].Meta.Confidence > scores[j].Score*scores[j].Meta.Confidence
+	})
+	return scores
 }
 
-func (s *Service) SanitizeString(str string) string {
-	regex := regexp.MustCompile(`[^a-zA-Z0-9\s\:\-]`)
-	return regex.ReplaceAllString(str, "")
+// variants returns the title and artist variants candidates are scored
+// against, and the cover artist named in the title, if any.
+func (s *Service) variants(ctx context.Context, trackMeta TrackMeta) ([]string, []string, string) {
+	coverArtist := s.CoverArtistCheck(ctx, trackMeta.Title)
+	if coverArtist != "" {
+		zaplog.InfoC(ctx, "cover artist found", zap.String("coverArtist", coverArtist))
+	}
+	sanitizedTitle := s.SanitizeString(s.SanitizeParenthesis(trackMeta.Title))
+	featStrippedTitle := strings.Split(sanitizedTitle, "feat")[0]
+	titles, artists := s.matchVariants(trackMeta, sanitizedTitle, featStrippedTitle, coverArtist)
+	zaplog.InfoC(ctx, "titles", zap.Strings("titles", titles))
+	zaplog.InfoC(ctx, "artists", zap.Strings("artists", artists))
+	return titles, artists, coverArtist
 }
 
-func (s *Service) SanitizeParenthesis(str string) string {
-	regex := regexp.MustCompile(`\([^\(\)]*\)|\[[^\[\]]*\]`)
-	return regex.ReplaceAllString(str, "")
+// fallbackMeta tags the track with its sanitized YouTube title when no
+// provider has a match.
+func (s *Service) fallbackMeta(trackMeta TrackMeta) TrackMeta {
+	return TrackMeta{Title: s.SanitizeString(s.SanitizeParenthesis(trackMeta.Title)), Artist: trackMeta.Artist, Genre: trackMeta.Genre, CoverArtURL: trackMeta.CoverArtURL}
 }
 
-func (s *Service) EqualIgnoringWhitespace(s1, s2 string) bool {
-	// Remove all whitespace from both strings
-	regex := regexp.MustCompile(`\s+`)
-	cleanS1 := regex.ReplaceAllString(s1, "")
-	cleanS2 := regex.ReplaceAllString(s2, "")
+func (s *Service) SanitizeString(str string) string {
+	regex := regexp.MustCompile(`[^\p{L}\p{M}\p{N}\s\:\-]`)
+	return regex.ReplaceAllString(norm.NFKC.String(str), "")
+}
 
-	// Compare the cleaned strings
-	return strings.EqualFold(cleanS1, cleanS2)
+// SanitizeParenthesis runs the title rewrite rules, which by default drop
+// bracketed text that does not name a version.
+func (s *Service) SanitizeParenthesis(str string) string {
+	return s.Rewrite(str, "").Title
 }
 
+// CoverArtistCheck returns the cover artist a title rewrite rule captured,
+// if any.
 func (s *Service) CoverArtistCheck(ctx context.Context, str string) string {
-	str = strings.ToLower(str)
-	parenthesisReg := regexp.MustCompile(`\([^\(\)]*\)|\[[^\[\]]*\]`)
-	inParenthesis := parenthesisReg.FindAllString(str, -1)
-	if len(inParenthesis) > 0 {
-		for _, inParenthesisStr := range inParenthesis {
-			if strings.Contains(strings.Trim(inParenthesisStr, " "), "cover by") {
-				return strings.Trim(strings.Replace(inParenthesisStr, "cover by", "", -1), " ")
-			} else if strings.Contains(strings.Trim(inParenthesisStr, " "), "covered by") {
-				return strings.Trim(strings.Replace(inParenthesisStr, "covered by", "", -1), " ")
-			} else if strings.HasSuffix(strings.Trim(inParenthesisStr, " "), "cover") {
-				return strings.Trim(strings.Replace(inParenthesisStr, "cover", "", -1), " ")
-			}
-		}
-	}
-	return ""
+	return s.Rewrite(str, "").CoverArtist
 }
 
+// SanitizeAuthor runs the artist rewrite rules, which by default drop channel
+// suffixes such as VEVO and Topic.
 func (s *Service) SanitizeAuthor(author string) string {
-	author = strings.ToLower(author)
-	r := regexp.MustCompile(` - official|-official|official| - vevo|-vevo|vevo|@| - topic|-topic|topic`)
-	author = r.ReplaceAllString(author, "")
-	author = strings.Trim(author, " ")
-	return author
+	return strings.Trim(strings.ToLower(s.Rewrite("", author).Artist), " ")
 }
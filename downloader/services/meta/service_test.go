@@ -0,0 +1,26 @@
+package meta
+
+import "testing"
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii", "Don't Stop: Part 1 - Live", "Dont Stop: Part 1 - Live"},
+		{"keeps accents", "Beyoncé", "Beyoncé"},
+		{"keeps japanese", "夜に駆ける", "夜に駆ける"},
+		{"keeps cyrillic", "Группа крови", "Группа крови"},
+		{"folds full width", "ＡＢＣ！", "ABC"},
+		{"drops symbols", "Song ★ #1 & more?", "Song  1  more"},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.SanitizeString(tt.in); got != tt.want {
+				t.Errorf("SanitizeString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package meta
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldReplacer handles letters that do not decompose into a base letter and
+// a combining mark.
+var foldReplacer = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ð", "d", "þ", "th", "ı", "i")
+
+var (
+	featRegex        = regexp.MustCompile(`\b(featuring|feat|ft|with)\b`)
+	conjunctionRegex = regexp.MustCompile(`(^|\s)(and|&|\+)(\s|$)`)
+	xRegex           = regexp.MustCompile(`\sx\s`)
+	nonWordRegex     = regexp.MustCompile(`[^\p{L}\p{N}&+]+`)
+)
+
+// NormalizeForMatch reduces a title or artist to a form that compares equal
+// across accents, case, punctuation and the usual spellings of "featuring".
+// Conjunctions ("&", "and", "x") are dropped entirely so that "A & B", "A and
+// B", "A x B" and "A, B" all normalize to the same string.
+func NormalizeForMatch(str string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), str)
+	if err != nil {
+		folded = str
+	}
+	folded = foldReplacer.Replace(strings.ToLower(folded))
+	folded = nonWordRegex.ReplaceAllString(folded, " ")
+	folded = featRegex.ReplaceAllString(folded, "feat")
+	// Run twice so that adjacent conjunctions sharing a space are both removed.
+	folded = conjunctionRegex.ReplaceAllString(folded, " ")
+	folded = conjunctionRegex.ReplaceAllString(folded, " ")
+	folded = xRegex.ReplaceAllString(folded, " ")
+	folded = xRegex.ReplaceAllString(folded, " ")
+	return strings.Join(strings.Fields(folded), " ")
+}
+
+// Similarity returns how alike two titles or artists are, between 0 and 1.
+// Both strings are normalized first, and the score is the better of a plain
+// edit-distance ratio and the same ratio over alphabetically sorted words, so
+// reordered tokens ("Beyoncé, Jay-Z" vs "Jay Z & Beyonce") still match.
+func Similarity(a, b string) float64 {
+	a = NormalizeForMatch(a)
+	b = NormalizeForMatch(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	score := levenshteinRatio(a, b)
+	if sorted := levenshteinRatio(sortTokens(a), sortTokens(b)); sorted > score {
+		score = sorted
+	}
+	return score
+}
+
+func sortTokens(str string) string {
+	tokens := strings.Fields(str)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+func levenshteinRatio(a, b string) float64 {
+	runesA := []rune(a)
+	runesB := []rune(b)
+	longest := len(runesA)
+	if len(runesB) > longest {
+		longest = len(runesB)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(runesA, runesB))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
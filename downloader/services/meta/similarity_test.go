@@ -0,0 +1,72 @@
+package meta
+
+import "testing"
+
+func TestNormalizeForMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"lowercases and trims", "  Hello   World ", "hello world"},
+		{"strips accents", "Beyoncé", "beyonce"},
+		{"folds non-decomposing letters", "Mø - Ærø", "mo aero"},
+		{"drops punctuation", "Don't Stop (Me Now)!", "don t stop me now"},
+		{"drops ampersand", "Simon & Garfunkel", "simon garfunkel"},
+		{"drops and", "Simon and Garfunkel", "simon garfunkel"},
+		{"drops x between artists", "Marshmello x Bastille", "marshmello bastille"},
+		{"keeps trailing x", "Malcolm X", "malcolm x"},
+		{"canonical feat.", "Song feat. Artist", "song feat artist"},
+		{"canonical ft.", "Song ft. Artist", "song feat artist"},
+		{"canonical featuring", "Song featuring Artist", "song feat artist"},
+		{"canonical with", "Song (with Artist)", "song feat artist"},
+		{"keeps words containing with", "Without Me", "without me"},
+		{"full width characters", "ＡＢＣ", "abc"},
+		{"keeps japanese", "夜に駆ける", "夜に駆ける"},
+		{"keeps cyrillic", "Кино - Группа крови", "кино группа крови"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeForMatch(tt.in); got != tt.want {
+				t.Errorf("NormalizeForMatch(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		min  float64
+		max  float64
+	}{
+		{"identical", "Bohemian Rhapsody", "Bohemian Rhapsody", 1, 1},
+		{"case and whitespace", "bohemian  rhapsody", "Bohemian Rhapsody", 1, 1},
+		{"accent difference", "Beyonce", "Beyoncé", 1, 1},
+		{"ampersand vs and", "Simon & Garfunkel", "Simon and Garfunkel", 1, 1},
+		{"x vs comma", "Marshmello x Bastille", "Marshmello, Bastille", 1, 1},
+		{"feat vs ft", "Stay feat. Justin Bieber", "Stay ft. Justin Bieber", 1, 1},
+		{"feat vs with", "Señorita (with Camila Cabello)", "Senorita feat. Camila Cabello", 1, 1},
+		{"token reordering", "Beyoncé, Jay-Z", "Jay Z & Beyonce", 1, 1},
+		{"single typo", "Bohemian Rhapsody", "Bohemian Rapsody", 0.9, 0.99},
+		{"missing word", "Don't Stop Me Now", "Don't Stop Me", 0.7, 0.9},
+		{"unrelated", "Bohemian Rhapsody", "Hotel California", 0, 0.3},
+		{"empty", "", "Hotel California", 0, 0},
+		{"punctuation only", "!!!", "!!!", 0, 0},
+		{"japanese identical", "夜に駆ける", "夜に駆ける", 1, 1},
+		{"japanese different", "夜に駆ける", "群青", 0, 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Similarity(%q, %q) = %.3f, want between %.2f and %.2f", tt.a, tt.b, got, tt.min, tt.max)
+			}
+			if reverse := Similarity(tt.b, tt.a); reverse != got {
+				t.Errorf("Similarity is not symmetric: %.3f vs %.3f", got, reverse)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package meta
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// writeExtraFrames rewrites the ID3 tag at the front of data with the extra
+// frames added. Frames are replaced when a frame with the same description or
+// owner already exists, and empty values remove the frame.
+func writeExtraFrames(data []byte, extra ExtraFrames) ([]byte, error) {
+	tag, err := id3v2.ParseReader(bytes.NewReader(data), id3v2.Options{Parse: true})
+	if err != nil {
+		return nil, err
+	}
+	encoding := tag.DefaultEncoding()
+	for description, value := range extra.UserText {
+		if value == "" {
+			deleteFrame(tag, "TXXX", description)
+			continue
+		}
+		tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{Encoding: encoding, Description: description, Value: value})
+	}
+	for description, values := range extra.UserTextValues {
+		if len(values) == 0 {
+			deleteFrame(tag, "TXXX", description)
+			continue
+		}
+		tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{Encoding: encoding, Description: description, Value: joinTextValues(tag.Version(), values)})
+	}
+	for id, values := range extra.TextValues {
+		tag.DeleteFrames(id)
+		if len(values) > 0 {
+			tag.AddTextFrame(id, encoding, joinTextValues(tag.Version(), values))
+		}
+	}
+	for owner, identifier := range extra.UniqueFileIDs {
+		if identifier == "" {
+			deleteFrame(tag, "UFID", owner)
+			continue
+		}
+		tag.AddUFIDFrame(id3v2.UFIDFrame{OwnerIdentifier: owner, Identifier: []byte(identifier)})
+	}
+	if extra.Comment != "" {
+		deleteFrame(tag, "COMM", "eng")
+		tag.AddCommentFrame(id3v2.CommentFrame{Encoding: encoding, Language: "eng", Text: extra.Comment})
+	}
+	if extra.Lyrics != "" {
+		writeLyricsFrames(tag, encoding, extra.Lyrics, extra.LyricsLanguage)
+	}
+	if extra.CoverArt != nil {
+		tag.DeleteFrames("APIC")
+		tag.AddAttachedPicture(id3v2.PictureFrame{Encoding: encoding, MimeType: "image/jpeg", PictureType: id3v2.PTFrontCover, Description: "Front cover", Picture: extra.CoverArt})
+	}
+	output := new(bytes.Buffer)
+	if _, err := tag.WriteTo(output); err != nil {
+		return nil, err
+	}
+	output.Write(data[id3TagSize(data):])
+	return output.Bytes(), nil
+}
+
+// joinTextValues joins the values of a multi-value text frame. ID3v2.4
+// separates values with a null byte; v2.3 has no separator, so the "/" most
+// players split on is used.
+func joinTextValues(version byte, values []string) string {
+	if version >= 4 {
+		return strings.Join(values, "\x00")
+	}
+	return strings.Join(values, "/")
+}
+
+// displayTextValues joins the values of a multi-value text frame written by
+// joinTextValues with ", ", for file names and the track table.
+func displayTextValues(version byte, value string) string {
+	separator := "\x00"
+	if version < 4 {
+		separator = "/"
+	}
+	values := strings.Split(value, separator)
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(values, ", ")
+}
+
+// deleteFrame removes the frame with the given id whose unique identifier
+// (the TXXX description, UFID owner, ...) matches, keeping the others.
+func deleteFrame(tag *id3v2.Tag, id string, uniqueIdentifier string) {
+	frames := tag.GetFrames(id)
+	tag.DeleteFrames(id)
+	for _, frame := range frames {
+		if frame.UniqueIdentifier() != uniqueIdentifier {
+			tag.AddFrame(id, frame)
+		}
+	}
+}
+
+// id3TagSize returns the number of bytes taken by the ID3v2 tag at the front
+// of data, including the header and footer, or 0 if there is no tag.
+func id3TagSize(data []byte) int {
+	if len(data) < 10 || string(data[:3]) != "ID3" {
+		return 0
+	}
+	size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+	size += 10
+	if data[5]&0x10 != 0 {
+		size += 10
+	}
+	if size > len(data) {
+		return len(data)
+	}
+	return size
+}
+
+// id3Version returns the major version of the ID3v2 tag at the front of
+// data, or 0 if there is no tag.
+func id3Version(data []byte) byte {
+	if id3TagSize(data) == 0 {
+		return 0
+	}
+	return data[3]
+}
+
+// frontCover returns the picture of the first APIC frame in data, or nil if
+// there is none.
+func frontCover(data []byte) ([]byte, error) {
+	tag, err := id3v2.ParseReader(bytes.NewReader(data), id3v2.Options{Parse: true, ParseFrames: []string{"Attached picture"}})
+	if err != nil {
+		return nil, err
+	}
+	for _, frame := range tag.GetFrames("APIC") {
+		if picture, ok := frame.(id3v2.PictureFrame); ok {
+			return picture.Picture, nil
+		}
+	}
+	return nil, nil
+}
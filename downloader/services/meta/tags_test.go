@@ -0,0 +1,45 @@
+package meta
+
+import "testing"
+
+func TestJoinTextValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		version byte
+		values  []string
+		want    string
+	}{
+		{"v2.4 null separated", 4, []string{"A", "B"}, "A\x00B"},
+		{"v2.3 slash separated", 3, []string{"A", "B"}, "A/B"},
+		{"single value", 4, []string{"A"}, "A"},
+		{"no values", 3, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinTextValues(tt.version, tt.values); got != tt.want {
+				t.Errorf("joinTextValues(%d, %q) = %q, want %q", tt.version, tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayTextValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		version byte
+		value   string
+		want    string
+	}{
+		{"v2.4 null separated", 4, "A\x00B", "A, B"},
+		{"v2.3 slash separated", 3, "A / B", "A, B"},
+		{"v2.4 keeps slashes", 4, "AC/DC", "AC/DC"},
+		{"single value", 3, "A", "A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayTextValues(tt.version, tt.value); got != tt.want {
+				t.Errorf("displayTextValues(%d, %q) = %q, want %q", tt.version, tt.value, got, tt.want)
+			}
+		})
+	}
+}
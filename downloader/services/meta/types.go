@@ -2,44 +2,292 @@ package meta
 
 import (
 	"context"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gcottom/yt-dl-services/downloader/config"
 	"github.com/gcottom/yt-dl-services/downloader/pkg/http_client"
 	"github.com/gcottom/yt-dl-services/downloader/track_sql"
+	"github.com/zmb3/spotify/v2"
+	spotifyauth "github.com/zmb3/spotify/v2/auth"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
 
 type MetaService interface {
+	ApplyMeta(ctx context.Context, data []byte, trackData track_sql.Track, trackMeta TrackMeta) ([]byte, error)
+	ApplyOverride(ctx context.Context, data []byte, overrides TrackMeta) ([]byte, TrackMeta, error)
+	ApplyVersion(ytTitle string, trackMeta TrackMeta) TrackMeta
 	CoverArtistCheck(ctx context.Context, str string) string
-	EqualIgnoringWhitespace(s1, s2 string) bool
-	GetBestMetaMatch(ctx context.Context, trackMeta TrackMeta, spotifyMetas []TrackMeta) TrackMeta
+	CreditArtists(trackMeta TrackMeta) TrackMeta
+	ExplainMeta(ctx context.Context, trackData track_sql.Track) (MetaExplanation, error)
+	FindATVVersion(ctx context.Context, id string) (string, error)
+	GetCoverArt(ctx context.Context, url string) ([]byte, error)
+	NormalizeCoverArt(data []byte) ([]byte, error)
+	PreviewRewrite(title string, artist string, rules []config.RewriteRule) (RewriteResult, error)
+	GetBestMetaMatch(ctx context.Context, trackMeta TrackMeta, candidates []TrackMeta) TrackMeta
 	GetSpotifyToken(ctx context.Context) (*oauth2.Token, error)
 	GetSpotifyMeta(ctx context.Context, trackMeta TrackMeta) ([]TrackMeta, error)
 	GetYTMetaFromID(ctx context.Context, trackData track_sql.Track) (TrackMeta, error)
+	MatchThreshold() float64
+	RankCandidates(ctx context.Context, trackMeta TrackMeta, candidates []TrackMeta) []CandidateScore
+	ResolveMeta(ctx context.Context, trackData track_sql.Track) (MetaResolution, error)
+	Rewrite(title string, artist string) RewriteResult
 	SaveMeta(ctx context.Context, data []byte, trackData track_sql.Track) ([]byte, TrackMeta, error)
 	SanitizeAuthor(author string) string
 	SanitizeParenthesis(str string) string
 	SanitizeString(str string) string
+	SearchProviders(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) []TrackMeta
+}
+
+// MetadataProvider searches an external source for releases matching the
+// metadata YouTube has for a video.
+type MetadataProvider interface {
+	Name() string
+	Search(ctx context.Context, trackMeta TrackMeta, trackData track_sql.Track) ([]TrackMeta, error)
+}
+
+// ChainedProvider is a provider in the configured chain together with the
+// confidence its results are weighted by.
+type ChainedProvider struct {
+	Provider   MetadataProvider
+	Confidence float64
+}
+
+func NewMetaService(cfg *config.Config, httpClient *http_client.HTTPClient, trackSQL *track_sql.Client) *Service {
+	s := &Service{
+		Config:        cfg,
+		HTTPClient:    httpClient,
+		TrackSQL:      trackSQL,
+		SpotifyConfig: &clientcredentials.Config{ClientID: cfg.Spotify.ClientID, ClientSecret: cfg.Spotify.ClientSecret, TokenURL: spotifyauth.TokenURL},
+	}
+	coverArtTimeout := time.Duration(cfg.CoverArt.Timeout) * time.Second
+	if coverArtTimeout <= 0 {
+		coverArtTimeout = 15 * time.Second
+	}
+	s.CoverArtHTTPClient = &http.Client{Timeout: coverArtTimeout}
+	// The client credentials token source caches the token and only requests
+	// a new one when it expires, so every search shares it. Rate limited
+	// requests are retried by the client after the Retry-After delay.
+	s.SpotifyTokenSource = s.SpotifyConfig.TokenSource(context.Background())
+	s.SpotifyClient = spotify.New(oauth2.NewClient(context.Background(), s.SpotifyTokenSource), spotify.WithRetry(true))
+	s.Providers = s.buildProviderChain()
+	s.RewriteRules = s.buildRewriteRules()
+	return s
 }
 
 type Service struct {
-	Config        *config.Config
-	HTTPClient    *http_client.HTTPClient
-	SpotifyConfig *clientcredentials.Config
+	Config             *config.Config
+	HTTPClient         *http_client.HTTPClient
+	TrackSQL           *track_sql.Client
+	SpotifyConfig      *clientcredentials.Config
+	SpotifyTokenSource oauth2.TokenSource
+	SpotifyClient      *spotify.Client
+	CoverArtHTTPClient *http.Client
+	CoverArtLocksMu    sync.Mutex
+	CoverArtLocks      map[string]*coverArtLock
+	Providers          []ChainedProvider
+	RewriteRules       []RewriteRule
 }
 
 type TrackMeta struct {
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	AlbumArtist string `json:"albumArtist"`
+	Genre       string `json:"genre"`
+	CoverArtURL string `json:"coverArtURL"`
+	CoverArt    []byte `json:"-"` // JPEG used instead of CoverArtURL
+	TrackNumber int    `json:"trackNumber"`
+	DiscNumber  int    `json:"discNumber"`
+	ReleaseDate string `json:"releaseDate"`
+	Year        int    `json:"year"`
+	ISRC        string `json:"isrc"`
+	Duration    int    `json:"duration"` // length in milliseconds
+	Explicit    bool   `json:"explicit"`
+	Label       string `json:"label"`
+	Copyright   string `json:"copyright"`
+	Composer    string `json:"composer"` // multiple separated by /
+	Lyricist    string `json:"lyricist"` // multiple separated by /
+
+	Version         string   `json:"version"`         // e.g. Live at Wembley, Acoustic; empty for the studio version
+	Artists         []string `json:"artists"`         // main artists, in credit order
+	FeaturedArtists []string `json:"featuredArtists"` // artists credited with feat.
+
+	MusicBrainzRecordingID    string `json:"musicBrainzRecordingID"`
+	MusicBrainzReleaseID      string `json:"musicBrainzReleaseID"`
+	MusicBrainzReleaseGroupID string `json:"musicBrainzReleaseGroupID"`
+	MusicBrainzArtistID       string `json:"musicBrainzArtistID"`      // multiple separated by /
+	MusicBrainzAlbumArtistID  string `json:"musicBrainzAlbumArtistID"` // multiple separated by /
+
+	Provider         string  `json:"provider"`
+	Confidence       float64 `json:"confidence"`
+	MatchScore       float64 `json:"matchScore"`
+	FingerprintScore float64 `json:"fingerprintScore,omitempty"` // how surely AcoustID identified the audio as this recording
+
+	Lyrics         string `json:"lyrics,omitempty"`         // LRC when timed with the audio, otherwise plain text
+	LyricsLanguage string `json:"lyricsLanguage,omitempty"` // BCP 47, e.g. en
+}
+
+// DescriptionMeta is the release information parsed from the description of
+// an auto-generated "Provided to YouTube by" upload.
+type DescriptionMeta struct {
+	Label       string
 	Title       string
-	Artist      string
+	Artists     []string
 	Album       string
-	Genre       string
-	CoverArtURL string
+	Copyright   string
+	ReleaseDate string
+	Year        int
+	Credits     map[string][]string
+}
+
+// MetaResolution is the outcome of looking a track up with the metadata
+// providers.
+type MetaResolution struct {
+	YTMeta     TrackMeta
+	Best       TrackMeta
+	Candidates []CandidateScore // every candidate, most trusted first
+}
+
+// MetaExplanation describes how the metadata for a track was chosen.
+type MetaExplanation struct {
+	YTMeta      TrackMeta              `json:"ytMeta"`
+	Titles      []string               `json:"titles"`
+	Artists     []string               `json:"artists"`
+	CoverArtist string                 `json:"coverArtist"`
+	Classes     []string               `json:"classes"` // set by rewrite rules, e.g. live or remix
+	Threshold   float64                `json:"threshold"`
+	Candidates  []CandidateExplanation `json:"candidates"`
+	Fallback    bool                   `json:"fallback"` // no candidate matched, the YouTube title is used
+	Final       TrackMeta              `json:"final"`
+}
+
+type CandidateExplanation struct {
+	CandidateScore
+	Selected bool   `json:"selected"`
+	Reason   string `json:"reason"`
+}
+
+// CandidateScore is the breakdown of how well a search result matches the
+// YouTube metadata it was searched for.
+type CandidateScore struct {
+	Meta           TrackMeta `json:"meta"`
+	TitleScore     float64   `json:"titleScore"`
+	ArtistScore    float64   `json:"artistScore"`
+	DurationScore  float64   `json:"durationScore"`
+	VersionPenalty float64   `json:"versionPenalty"`
+	Rejected       string    `json:"rejected,omitempty"` // why the candidate can never match
+	Score          float64   `json:"score"`
+}
+
+// ExtraFrames holds the ID3 frames that mp3meta has no setters for.
+type ExtraFrames struct {
+	UserText       map[string]string
+	TextValues     map[string][]string // multi-value text frames keyed by frame ID
+	UserTextValues map[string][]string // multi-value TXXX frames keyed by description
+	UniqueFileIDs  map[string]string   // UFID identifiers keyed by owner
+	Comment        string              // COMM frame without a description, left as is when empty
+	CoverArt       []byte              // JPEG front cover, replacing any other
+	Lyrics         string              // LRC or plain text for the USLT and SYLT frames, left as is when empty
+	LyricsLanguage string              // ISO 639-2 language of Lyrics
 }
 
 type YTMMetaResponse struct {
-	Title  string `json:"title"`
-	Author string `json:"author"`
-	Image  string `json:"image"`
-	Type   string `json:"type"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Image    string `json:"image"`
+	Type     string `json:"type"`
+	Duration int    `json:"duration"`
+}
+
+type ATVResponse struct {
+	Title      string         `json:"title"`
+	Author     string         `json:"author"`
+	Type       string         `json:"type"`
+	Duration   int            `json:"duration"`
+	Candidates []ATVCandidate `json:"candidates"`
+}
+
+type ATVCandidate struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Duration int    `json:"duration"`
+}
+
+type AcoustIDResponse struct {
+	Status  string           `json:"status"`
+	Results []AcoustIDResult `json:"results"`
+	Error   struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type AcoustIDResult struct {
+	ID         string              `json:"id"`
+	Score      float64             `json:"score"`
+	Recordings []AcoustIDRecording `json:"recordings"`
+}
+
+type AcoustIDRecording struct {
+	ID            string           `json:"id"`
+	Title         string           `json:"title"`
+	Duration      float64          `json:"duration"` // seconds
+	Artists       []AcoustIDArtist `json:"artists"`
+	ReleaseGroups []struct {
+		ID             string   `json:"id"`
+		Title          string   `json:"title"`
+		Type           string   `json:"type"`
+		SecondaryTypes []string `json:"secondarytypes"`
+	} `json:"releasegroups"`
+}
+
+type AcoustIDArtist struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+}
+
+type MusicBrainzRecordingResponse struct {
+	Recordings []MusicBrainzRecording `json:"recordings"`
+}
+
+type MusicBrainzRecording struct {
+	ID           string                   `json:"id"`
+	Title        string                   `json:"title"`
+	Length       int                      `json:"length"`
+	ArtistCredit MusicBrainzArtistCredits `json:"artist-credit"`
+	ISRCs        []string                 `json:"isrcs"`
+	Releases     []MusicBrainzRelease     `json:"releases"`
+}
+
+type MusicBrainzArtistCredits []MusicBrainzArtistCredit
+
+type MusicBrainzArtistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+	Artist     struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+}
+
+type MusicBrainzRelease struct {
+	ID           string                   `json:"id"`
+	Title        string                   `json:"title"`
+	Status       string                   `json:"status"`
+	Date         string                   `json:"date"`
+	ArtistCredit MusicBrainzArtistCredits `json:"artist-credit"`
+	ReleaseGroup struct {
+		ID string `json:"id"`
+	} `json:"release-group"`
+	Media []struct {
+		Position int `json:"position"`
+		Track    []struct {
+			ID     string `json:"id"`
+			Number string `json:"number"`
+		} `json:"track"`
+	} `json:"media"`
 }
@@ -0,0 +1,80 @@
+package meta
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// versionQualifierRegex finds bracketed text and a trailing " - ..."
+	// part, where titles name their version.
+	versionQualifierRegex = regexp.MustCompile(`[(\[（【［]([^()\[\]（）【】［］]+)[)\]）】］]|\s[-–—]\s([^-–—()\[\]（）【】［］]+)$`)
+	// versionNoiseRegex removes the words uploads wrap a version in, so
+	// "(Official Live Video)" is just "Live".
+	versionNoiseRegex = regexp.MustCompile(`(?i)\b(?:official|music|lyrics?|video|audio|visuali[sz]er|hd|hq|4k|mv)\b`)
+	// trailingVersionRegex is how a version is named after a dash, where the
+	// keyword has to end the title or lead into a place, a year or a word
+	// like "version", so "Song - Live at Wembley" is a version but a song
+	// called "Live Forever" is not.
+	trailingVersionRegex = regexp.MustCompile(`(?i)\b(?:` + strings.Join(versionKeywords, "|") + `)(?:\s+(?:version|mix|edit|sessions?|recording|take|at|from|in|on|with|\d{4})\b|$)`)
+)
+
+// DetectVersion returns the qualifier that names the version of a title,
+// such as "Live at Wembley" or "Acoustic", or "" for the studio version.
+func DetectVersion(title string) string {
+	for _, match := range versionQualifierRegex.FindAllStringSubmatch(title, -1) {
+		qualifier := strings.Join(strings.Fields(versionNoiseRegex.ReplaceAllString(match[1]+match[2], "")), " ")
+		if match[2] != "" && !trailingVersionRegex.MatchString(qualifier) {
+			continue
+		}
+		if len(versionKinds(qualifier)) > 0 {
+			return qualifier
+		}
+	}
+	return ""
+}
+
+// versionKinds returns the version keywords str contains.
+func versionKinds(str string) []string {
+	str = strings.ToLower(str)
+	kinds := make([]string, 0)
+	for _, keyword := range versionKeywords {
+		if versionKeywordRegexes[keyword].MatchString(str) {
+			kinds = append(kinds, keyword)
+		}
+	}
+	return kinds
+}
+
+// ApplyVersion sets the version of a resolved track from the matched
+// candidate, or from the YouTube title when the candidate names none, and
+// makes sure the title carries it so versions are not named like the studio
+// track.
+func (s *Service) ApplyVersion(ytTitle string, best TrackMeta) TrackMeta {
+	if best.Version == "" {
+		best.Version = DetectVersion(best.Title)
+	}
+	if best.Version == "" {
+		// Uploads are usually titled "Artist - Title", and the artist part
+		// is not a version.
+		if _, title, ok := strings.Cut(ytTitle, " - "); ok {
+			ytTitle = title
+		}
+		best.Version = DetectVersion(ytTitle)
+	}
+	if best.Version != "" && !strings.Contains(NormalizeForMatch(best.Title), NormalizeForMatch(best.Version)) {
+		best.Title = strings.TrimSpace(best.Title) + " (" + best.Version + ")"
+	}
+	return best
+}
+
+// studioMismatch reports why a candidate cannot be the version uploaded: a
+// live recording is never matched to a studio release. Only the version the
+// title names counts, so a song called "Live Forever" is not a live upload.
+func studioMismatch(ytTitle string, candidateText string) string {
+	live := versionKeywordRegexes["live"]
+	if live.MatchString(strings.ToLower(DetectVersion(ytTitle))) && !live.MatchString(strings.ToLower(candidateText)) {
+		return "live upload but studio candidate"
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package meta
+
+import "testing"
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"studio", "Artist - Song", ""},
+		{"official video", "Artist - Song (Official Video)", ""},
+		{"bracketed live", "Artist - Song (Live at Wembley)", "Live at Wembley"},
+		{"noise around the version", "Artist - Song (Official Live Video)", "Live"},
+		{"square brackets", "Song [Acoustic]", "Acoustic"},
+		{"full-width brackets", "Song【Remix】", "Remix"},
+		{"named remix", "Song (Someone Remix)", "Someone Remix"},
+		{"trailing live at", "Song - Live at Wembley", "Live at Wembley"},
+		{"trailing live with year", "Song - Live 1998", "Live 1998"},
+		{"trailing radio edit", "Song - Radio Edit", "Radio Edit"},
+		{"trailing acoustic version", "Song - Acoustic Version", "Acoustic Version"},
+		{"trailing remix", "Song - Someone Remix", "Someone Remix"},
+		{"song title starting with live", "Artist - Live Forever", ""},
+		{"song title starting with demo", "Artist - Demolition Man", ""},
+		{"keyword inside a word", "Song (Delivered)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectVersion(tt.title); got != tt.want {
+				t.Errorf("DetectVersion(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		ytTitle     string
+		best        TrackMeta
+		wantTitle   string
+		wantVersion string
+	}{
+		{"studio", "Artist - Song", TrackMeta{Title: "Song"}, "Song", ""},
+		{"version from the upload", "Artist - Song (Live at Wembley)", TrackMeta{Title: "Song"}, "Song (Live at Wembley)", "Live at Wembley"},
+		{"version from the candidate", "Artist - Song", TrackMeta{Title: "Song - Acoustic"}, "Song - Acoustic", "Acoustic"},
+		{"candidate version kept", "Artist - Song (Live)", TrackMeta{Title: "Song", Version: "Demo"}, "Song (Demo)", "Demo"},
+		{"artist part is not a version", "Live - Song", TrackMeta{Title: "Song"}, "Song", ""},
+		{"song titled like a version", "Artist - Live Forever", TrackMeta{Title: "Live Forever"}, "Live Forever", ""},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.ApplyVersion(tt.ytTitle, tt.best)
+			if got.Title != tt.wantTitle || got.Version != tt.wantVersion {
+				t.Errorf("ApplyVersion(%q) = %q, version %q, want %q, version %q", tt.ytTitle, got.Title, got.Version, tt.wantTitle, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestStudioMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		ytTitle   string
+		candidate string
+		rejected  bool
+	}{
+		{"live upload, studio candidate", "Artist - Song (Live)", "Song Album", true},
+		{"live upload, live candidate", "Artist - Song (Live)", "Song (Live) Live Album", false},
+		{"studio upload", "Artist - Song", "Song Album", false},
+		{"song titled live", "Artist - Live Forever", "Live Forever Album", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := studioMismatch(tt.ytTitle, tt.candidate); (got != "") != tt.rejected {
+				t.Errorf("studioMismatch(%q, %q) = %q, want rejected %v", tt.ytTitle, tt.candidate, got, tt.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package segments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gcottom/go-zaplog"
+	"go.uber.org/zap"
+)
+
+// CategoryMusicOffTopic is the SponsorBlock category of the parts of a music
+// video that are not the song, removed when no categories are configured.
+const CategoryMusicOffTopic = "music_offtopic"
+
+// GetSegments returns the segments the configured segment database lists for
+// a video in the configured categories. A video the database has no segments
+// for has none.
+func (s *Service) GetSegments(ctx context.Context, videoID string) ([]Segment, error) {
+	categories := s.Config.Segments.Categories
+	if len(categories) == 0 {
+		categories = []string{CategoryMusicOffTopic}
+	}
+	encodedCategories, err := json.Marshal(categories)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"videoID": {videoID}, "categories": {string(encodedCategories)}}
+	endpoint := fmt.Sprintf("%s/api/skipSegments?%s", strings.TrimRight(s.Config.Segments.Endpoint, "/"), query.Encode())
+	req, err := s.HTTPClient.CreateRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to create request", zap.Error(err))
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, code, err := s.HTTPClient.DoRequest(req.WithContext(ctx))
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to do request", zap.Error(err))
+		return nil, fmt.Errorf("failed to do request: %w", err)
+	}
+	// SponsorBlock answers 404 for videos without segments.
+	if code == http.StatusNotFound {
+		return nil, nil
+	}
+	if code != http.StatusOK {
+		zaplog.ErrorC(ctx, "failed to get segments", zap.String("videoID", videoID), zap.Int("code", code))
+		return nil, fmt.Errorf("failed to get segments: %d", code)
+	}
+	var data []SponsorBlockSegment
+	if err := json.Unmarshal(resp, &data); err != nil {
+		zaplog.ErrorC(ctx, "failed to unmarshal segments", zap.Error(err))
+		return nil, fmt.Errorf("failed to unmarshal segments: %w", err)
+	}
+	segments := make([]Segment, 0, len(data))
+	for _, segment := range data {
+		// Other actions, such as mute or full, do not mark a part to cut.
+		if len(segment.Segment) != 2 || (segment.ActionType != "" && segment.ActionType != "skip") {
+			continue
+		}
+		start, end := seconds(segment.Segment[0]), seconds(segment.Segment[1])
+		if start < 0 {
+			start = 0
+		}
+		if end <= start {
+			continue
+		}
+		segments = append(segments, Segment{Start: start, End: end, Category: segment.Category, VideoDuration: seconds(segment.VideoDuration)})
+	}
+	zaplog.InfoC(ctx, "successfully retrieved segments", zap.String("videoID", videoID), zap.Int("count", len(segments)))
+	return segments, nil
+}
+
+// seconds converts a time in seconds to milliseconds.
+func seconds(s float64) int {
+	return int(math.Round(s * 1000))
+}
+
+// Merge sorts segments by start and joins the ones that overlap or touch.
+func Merge(segments []Segment) []Segment {
+	sorted := append([]Segment{}, segments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	merged := make([]Segment, 0, len(sorted))
+	for _, segment := range sorted {
+		if last := len(merged) - 1; last >= 0 && segment.Start <= merged[last].End {
+			merged[last].End = max(merged[last].End, segment.End)
+			if !slices.Contains(strings.Split(merged[last].Category, ","), segment.Category) {
+				merged[last].Category = strings.Join([]string{merged[last].Category, segment.Category}, ",")
+			}
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// Length returns how many milliseconds of audio segments cover.
+func Length(segments []Segment) int {
+	length := 0
+	for _, segment := range segments {
+		length += segment.End - segment.Start
+	}
+	return length
+}
+
+// Adjust maps a time in the original audio to the same moment in the audio
+// with segments cut out. The returned bool is false when the time falls
+// inside a cut segment.
+func Adjust(ms int, segments []Segment) (int, bool) {
+	adjusted := ms
+	for _, segment := range segments {
+		if ms < segment.Start {
+			break
+		}
+		if ms < segment.End {
+			return 0, false
+		}
+		adjusted -= segment.End - segment.Start
+	}
+	return adjusted, true
+}
+
+// Encode packs segments into a string for storage.
+func Encode(segments []Segment) string {
+	if len(segments) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(segments)
+	return string(encoded)
+}
+
+// Decode unpacks segments stored with Encode.
+func Decode(encoded string) ([]Segment, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	var segments []Segment
+	if err := json.Unmarshal([]byte(encoded), &segments); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
@@ -0,0 +1,143 @@
+package segments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gcottom/go-zaplog"
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/http_client"
+)
+
+func TestGetSegments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/skipSegments" {
+			t.Errorf("request path = %q, want /api/skipSegments", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("categories"); got != `["music_offtopic"]` {
+			t.Errorf("categories = %q, want [\"music_offtopic\"]", got)
+		}
+		switch r.URL.Query().Get("videoID") {
+		case "segments":
+			w.Write([]byte(`[
+				{"segment": [0, 12.5], "category": "music_offtopic", "actionType": "skip", "videoDuration": 240.3},
+				{"segment": [50, 60], "category": "music_offtopic", "actionType": "mute", "videoDuration": 240.3},
+				{"segment": [0, 240.3], "category": "music_offtopic", "actionType": "full", "videoDuration": 240.3},
+				{"segment": [200.001, 240], "category": "music_offtopic", "videoDuration": 0},
+				{"segment": [30, 30], "category": "music_offtopic", "actionType": "skip"}
+			]`))
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Not Found"))
+		}
+	}))
+	defer server.Close()
+	cfg := &config.Config{}
+	cfg.Segments.Endpoint = server.URL + "/"
+	service := &Service{Config: cfg, HTTPClient: http_client.NewHTTPClient()}
+	ctx := zaplog.CreateAndInject(context.Background())
+
+	tests := []struct {
+		name    string
+		videoID string
+		want    []Segment
+		wantErr bool
+	}{
+		{"converts seconds and keeps only skips", "segments", []Segment{
+			{Start: 0, End: 12500, Category: "music_offtopic", VideoDuration: 240300},
+			{Start: 200001, End: 240000, Category: "music_offtopic"},
+		}, false},
+		{"no segments for a 404", "unknown", nil, false},
+		{"server error", "error", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.GetSegments(ctx, tt.videoID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSegments(%q) error = %v, wantErr %v", tt.videoID, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("GetSegments(%q) = %+v, want %+v", tt.videoID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Segment
+		want []Segment
+	}{
+		{"empty", nil, []Segment{}},
+		{"sorts", []Segment{{Start: 50, End: 60, Category: "a"}, {Start: 0, End: 10, Category: "a"}}, []Segment{{Start: 0, End: 10, Category: "a"}, {Start: 50, End: 60, Category: "a"}}},
+		{"joins overlapping", []Segment{{Start: 0, End: 10, Category: "a"}, {Start: 5, End: 20, Category: "a"}}, []Segment{{Start: 0, End: 20, Category: "a"}}},
+		{"joins touching", []Segment{{Start: 0, End: 10, Category: "a"}, {Start: 10, End: 20, Category: "a"}}, []Segment{{Start: 0, End: 20, Category: "a"}}},
+		{"keeps contained end", []Segment{{Start: 0, End: 30, Category: "a"}, {Start: 5, End: 10, Category: "a"}}, []Segment{{Start: 0, End: 30, Category: "a"}}},
+		{"lists each category once", []Segment{{Start: 0, End: 10, Category: "a"}, {Start: 5, End: 15, Category: "b"}, {Start: 12, End: 20, Category: "a"}}, []Segment{{Start: 0, End: 20, Category: "a,b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge(%+v) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	cuts := []Segment{{Start: 0, End: 15000}, {Start: 60000, End: 70000}}
+	tests := []struct {
+		name      string
+		ms        int
+		want      int
+		wantHeard bool
+	}{
+		{"inside the first cut", 5000, 0, false},
+		{"at the end of the first cut", 15000, 0, true},
+		{"between cuts", 20000, 5000, true},
+		{"at the start of the second cut", 60000, 0, false},
+		{"after both cuts", 80000, 55000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, heard := Adjust(tt.ms, cuts)
+			if got != tt.want || heard != tt.wantHeard {
+				t.Errorf("Adjust(%d) = %d, %v, want %d, %v", tt.ms, got, heard, tt.want, tt.wantHeard)
+			}
+		})
+	}
+	if got, heard := Adjust(1234, nil); got != 1234 || !heard {
+		t.Errorf("Adjust(1234) without cuts = %d, %v, want 1234, true", got, heard)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+	}{
+		{"none", nil},
+		{"one", []Segment{{Start: 0, End: 15000, Category: "sponsor", VideoDuration: 200000}}},
+		{"merged categories", []Segment{{Start: 0, End: 15000, Category: "intro,sponsor"}, {Start: 60000, End: 70000, Category: "music_offtopic"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(Encode(tt.segments))
+			if err != nil {
+				t.Fatalf("Decode(Encode()) error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.segments) {
+				t.Errorf("Decode(Encode(%+v)) = %+v", tt.segments, got)
+			}
+		})
+	}
+	if _, err := Decode("not json"); err == nil {
+		t.Error("Decode() of a malformed value succeeded")
+	}
+}
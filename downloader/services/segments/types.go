@@ -0,0 +1,36 @@
+package segments
+
+import (
+	"context"
+
+	"github.com/gcottom/yt-dl-services/downloader/config"
+	"github.com/gcottom/yt-dl-services/downloader/pkg/http_client"
+)
+
+type SegmentService interface {
+	GetSegments(ctx context.Context, videoID string) ([]Segment, error)
+}
+
+type Service struct {
+	Config     *config.Config
+	HTTPClient *http_client.HTTPClient
+}
+
+// Segment is a part of a video, such as a sponsor read or a non-music intro,
+// that is removed from the audio. Times are in milliseconds.
+type Segment struct {
+	Start         int    `json:"start"`
+	End           int    `json:"end"`
+	Category      string `json:"category"`
+	VideoDuration int    `json:"videoDuration"` // length of the video the segment was submitted for, 0 if unknown
+}
+
+// SponsorBlockSegment is a segment as the SponsorBlock API returns it. Times
+// are in seconds.
+type SponsorBlockSegment struct {
+	Segment       []float64 `json:"segment"`
+	UUID          string    `json:"UUID"`
+	Category      string    `json:"category"`
+	ActionType    string    `json:"actionType"`
+	VideoDuration float64   `json:"videoDuration"`
+}
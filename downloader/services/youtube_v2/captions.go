@@ -0,0 +1,110 @@
+package youtube_v2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gcottom/go-zaplog"
+	"go.uber.org/zap"
+)
+
+// captionNoise is caption text that marks music rather than lyrics.
+var captionNoise = strings.NewReplacer("♪", "", "♫", "", "[Music]", "", "[music]", "", "(Music)", "", "[Instrumental]", "")
+
+// GetCaptionTracks lists the caption tracks of a video.
+func (s *Service) GetCaptionTracks(ctx context.Context, videoID string) ([]CaptionTrack, error) {
+	zaplog.InfoC(ctx, "getting caption tracks", zap.String("videoID", videoID))
+	video, err := s.YTClient.GetVideoContext(ctx, videoID)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to get video info, retrying with embedded client", zap.String("videoID", videoID), zap.Error(err))
+		video, err = s.YTEmbeddedClient.GetVideoContext(ctx, videoID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get video info: %w", err)
+		}
+	}
+	tracks := make([]CaptionTrack, 0, len(video.CaptionTracks))
+	for _, track := range video.CaptionTracks {
+		tracks = append(tracks, CaptionTrack{
+			LanguageCode:  track.LanguageCode,
+			Name:          track.Name.SimpleText,
+			AutoGenerated: track.Kind == "asr",
+			URL:           track.BaseURL,
+		})
+	}
+	zaplog.InfoC(ctx, "successfully retrieved caption tracks", zap.String("videoID", videoID), zap.Int("count", len(tracks)))
+	return tracks, nil
+}
+
+// GetCaptions downloads the timed captions of a caption track. Captions that
+// only mark music playing are left out.
+func (s *Service) GetCaptions(ctx context.Context, track CaptionTrack) ([]Caption, error) {
+	req, err := s.HTTPClient.CreateRequest(http.MethodGet, track.URL+"&fmt=json3", nil)
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to create request", zap.Error(err))
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, code, err := s.HTTPClient.DoRequest(req.WithContext(ctx))
+	if err != nil {
+		zaplog.ErrorC(ctx, "failed to do request", zap.Error(err))
+		return nil, fmt.Errorf("failed to do request: %w", err)
+	}
+	if code != http.StatusOK {
+		zaplog.ErrorC(ctx, "failed to get captions", zap.String("language", track.LanguageCode), zap.Int("code", code))
+		return nil, fmt.Errorf("failed to get captions: %d", code)
+	}
+	var data struct {
+		Events []struct {
+			StartMs    int `json:"tStartMs"`
+			DurationMs int `json:"dDurationMs"`
+			Segs       []struct {
+				UTF8 string `json:"utf8"`
+			} `json:"segs"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(resp, &data); err != nil {
+		zaplog.ErrorC(ctx, "failed to unmarshal captions", zap.Error(err))
+		return nil, fmt.Errorf("failed to unmarshal captions: %w", err)
+	}
+	captions := make([]Caption, 0, len(data.Events))
+	for _, event := range data.Events {
+		var text strings.Builder
+		for _, seg := range event.Segs {
+			text.WriteString(seg.UTF8)
+		}
+		line := strings.Join(strings.Fields(captionNoise.Replace(text.String())), " ")
+		if line == "" {
+			continue
+		}
+		captions = append(captions, Caption{
+			Start:    time.Duration(event.StartMs) * time.Millisecond,
+			Duration: time.Duration(event.DurationMs) * time.Millisecond,
+			Text:     line,
+		})
+	}
+	return captions, nil
+}
+
+// CaptionsToLRC writes captions as LRC lyrics, one timed line per caption.
+func CaptionsToLRC(captions []Caption) string {
+	var lrc strings.Builder
+	for _, caption := range captions {
+		centiseconds := caption.Start.Milliseconds() / 10
+		fmt.Fprintf(&lrc, "[%02d:%02d.%02d]%s\n", centiseconds/6000, centiseconds/100%60, centiseconds%100, caption.Text)
+	}
+	return lrc.String()
+}
+
+// CaptionsToText writes the text of captions without their timing, for
+// captions that are not in time with the audio they are saved with.
+func CaptionsToText(captions []Caption) string {
+	var text strings.Builder
+	for _, caption := range captions {
+		text.WriteString(caption.Text)
+		text.WriteString("\n")
+	}
+	return text.String()
+}
@@ -0,0 +1,27 @@
+package youtube_v2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCaptionsToLRC(t *testing.T) {
+	tests := []struct {
+		name     string
+		captions []Caption
+		want     string
+	}{
+		{"none", nil, ""},
+		{"start of track", []Caption{{Start: 0, Text: "a"}}, "[00:00.00]a\n"},
+		{"centiseconds truncated", []Caption{{Start: 12345 * time.Millisecond, Text: "a"}}, "[00:12.34]a\n"},
+		{"minutes", []Caption{{Start: 62500 * time.Millisecond, Text: "a"}, {Start: 125 * time.Second, Text: "b"}}, "[01:02.50]a\n[02:05.00]b\n"},
+		{"over an hour", []Caption{{Start: 61 * time.Minute, Text: "a"}}, "[61:00.00]a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CaptionsToLRC(tt.captions); got != tt.want {
+				t.Errorf("CaptionsToLRC() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
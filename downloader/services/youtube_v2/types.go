@@ -2,6 +2,7 @@ package youtube_v2
 
 import (
 	"context"
+	"time"
 
 	"github.com/gcottom/semaphore"
 	"github.com/gcottom/yt-dl-services/downloader/config"
@@ -10,9 +11,33 @@ import (
 )
 
 type YoutubeService interface {
-	Download(ctx context.Context, id string, useEmbedded bool) ([]byte, error)
+	Download(ctx context.Context, id string, useEmbedded bool) ([]byte, int, error)
 	GetPlaylistEntries(ctx context.Context, playlistID string) ([]string, error)
-	GetVideoInfo(ctx context.Context, videoID string, useEmbedded bool) (string, string, error)
+	GetVideoInfo(ctx context.Context, videoID string, useEmbedded bool) (VideoInfo, error)
+	GetCaptionTracks(ctx context.Context, videoID string) ([]CaptionTrack, error)
+	GetCaptions(ctx context.Context, track CaptionTrack) ([]Caption, error)
+}
+
+type VideoInfo struct {
+	Title       string
+	Author      string
+	Description string
+	Duration    time.Duration
+}
+
+// CaptionTrack is one language of a video's captions.
+type CaptionTrack struct {
+	LanguageCode  string // BCP 47, e.g. en or pt-BR
+	Name          string
+	AutoGenerated bool // generated by speech recognition
+	URL           string
+}
+
+// Caption is a line of a caption track and when it is shown.
+type Caption struct {
+	Start    time.Duration
+	Duration time.Duration
+	Text     string
 }
 
 type Service struct {
@@ -13,7 +13,9 @@ import (
 	"go.uber.org/zap"
 )
 
-func (s *Service) Download(ctx context.Context, id string, useEmbedded bool) ([]byte, error) {
+// Download returns the best audio stream of a video and its bitrate in bits
+// per second.
+func (s *Service) Download(ctx context.Context, id string, useEmbedded bool) ([]byte, int, error) {
 	zaplog.InfoC(ctx, "fetching video info", zap.String("id", id))
 	var videoInfo *youtube.Video
 	var err error
@@ -28,14 +30,14 @@ func (s *Service) Download(ctx context.Context, id string, useEmbedded bool) ([]
 			zaplog.InfoC(ctx, "retrying with embedded client", zap.String("id", id))
 			return s.Download(ctx, id, true)
 		}
-		return nil, fmt.Errorf("failed to get video info: %w", err)
+		return nil, 0, fmt.Errorf("failed to get video info: %w", err)
 	}
 	zaplog.InfoC(ctx, "video info fetched", zap.String("id", id))
 	zaplog.InfoC(ctx, "getting best audio format", zap.String("id", id))
 	bestFormat := getBestAudioFormat(videoInfo.Formats.Type("audio"))
 	if bestFormat == nil {
 		zaplog.ErrorC(ctx, "failed to get best audio format", zap.String("id", id))
-		return nil, fmt.Errorf("failed to get best audio format")
+		return nil, 0, fmt.Errorf("failed to get best audio format")
 	}
 	zaplog.InfoC(ctx, "best audio format found", zap.String("id", id), zap.Int("bitrate", bestFormat.Bitrate))
 
@@ -63,16 +65,16 @@ func (s *Service) Download(ctx context.Context, id string, useEmbedded bool) ([]
 			zaplog.InfoC(ctx, "retrying with embedded client", zap.String("id", id))
 			return s.Download(ctx, id, true)
 		}
-		return nil, fmt.Errorf("failed to get stream: %w", err)
+		return nil, 0, fmt.Errorf("failed to get stream: %w", err)
 	}
 	defer stream.Close()
 	streamBytes, err := io.ReadAll(stream)
 	if err != nil {
 		zaplog.ErrorC(ctx, "failed to read stream", zap.String("id", id), zap.Error(err))
-		return nil, fmt.Errorf("failed to read stream: %w", err)
+		return nil, 0, fmt.Errorf("failed to read stream: %w", err)
 	}
 	zaplog.InfoC(ctx, "successfully downloaded youtube stream", zap.String("id", id))
-	return streamBytes, nil
+	return streamBytes, bestFormat.Bitrate, nil
 }
 
 func (s *Service) GetPlaylistEntries(ctx context.Context, playlistID string) ([]string, error) {
@@ -123,8 +125,8 @@ func (s *Service) GetPlaylistEntriesFromMusicAPI(ctx context.Context, playlistID
 	return entries, nil
 }
 
-// GetVideoInfo returns the title and author of a video
-func (s *Service) GetVideoInfo(ctx context.Context, videoID string, useEmbedded bool) (string, string, error) {
+// GetVideoInfo returns the title, author, description and duration of a video
+func (s *Service) GetVideoInfo(ctx context.Context, videoID string, useEmbedded bool) (VideoInfo, error) {
 	zaplog.InfoC(ctx, "getting video info", zap.String("videoID", videoID))
 	var video *youtube.Video
 	var err error
@@ -139,10 +141,10 @@ func (s *Service) GetVideoInfo(ctx context.Context, videoID string, useEmbedded
 			zaplog.InfoC(ctx, "retrying with embedded client", zap.String("videoID", videoID))
 			return s.GetVideoInfo(ctx, videoID, true)
 		}
-		return "", "", fmt.Errorf("failed to get video info: %w", err)
+		return VideoInfo{}, fmt.Errorf("failed to get video info: %w", err)
 	}
 	zaplog.InfoC(ctx, "successfully retrieved video info", zap.String("videoID", videoID))
-	return video.Title, video.Author, nil
+	return VideoInfo{Title: video.Title, Author: video.Author, Description: video.Description, Duration: video.Duration}, nil
 }
 
 func getBestAudioFormat(formats youtube.FormatList) *youtube.Format {
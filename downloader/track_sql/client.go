@@ -2,6 +2,7 @@ package track_sql
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/gcottom/semaphore"
 	"github.com/gcottom/yt-dl-services/downloader/config"
@@ -35,5 +36,89 @@ func CreateTables(db *sql.DB) error {
 		"error" INTEGER,
 		"error_message" TEXT
 	);`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS search_cache (
+		"key" TEXT NOT NULL PRIMARY KEY,
+		"value" TEXT NOT NULL,
+		"expires_at" INTEGER NOT NULL
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS review (
+		"id" TEXT NOT NULL PRIMARY KEY,
+		"title" TEXT NOT NULL,
+		"artist" TEXT NOT NULL,
+		"candidates" TEXT NOT NULL,
+		"created_at" INTEGER NOT NULL
+	);`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS meta_override (
+		"id" TEXT NOT NULL PRIMARY KEY,
+		"title" TEXT NOT NULL,
+		"artist" TEXT NOT NULL,
+		"album" TEXT NOT NULL,
+		"genre" TEXT NOT NULL,
+		"year" INTEGER NOT NULL,
+		"cover_art_url" TEXT NOT NULL,
+		"cover_art" BLOB
+	);`)
+	if err != nil {
+		return err
+	}
+	return MigrateTables(db)
+}
+
+// MigrateTables adds the columns introduced after the original schema to
+// databases created by older versions.
+func MigrateTables(db *sql.DB) error {
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"match_score", "REAL NOT NULL DEFAULT 0"},
+		{"description", "TEXT NOT NULL DEFAULT ''"},
+		{"duration", "INTEGER NOT NULL DEFAULT 0"},
+		{"source_id", "TEXT NOT NULL DEFAULT ''"},
+		{"path", "TEXT NOT NULL DEFAULT ''"},
+		{"path_template", "TEXT NOT NULL DEFAULT ''"},
+		{"content_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"fingerprint", "TEXT NOT NULL DEFAULT ''"},
+		{"duplicate_of", "TEXT NOT NULL DEFAULT ''"},
+		{"cuts", "TEXT NOT NULL DEFAULT ''"},
+		{"bitrate", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, column := range columns {
+		if err := addColumnIfMissing(db, "track", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addColumnIfMissing(db *sql.DB, table string, column string, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
@@ -0,0 +1,23 @@
+package track_sql
+
+import (
+	"context"
+
+	"github.com/gcottom/retry"
+)
+
+func (c *Client) PutOverride(ctx context.Context, override Override) error {
+	c.Semaphore.Acquire()
+	_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "INSERT OR REPLACE INTO meta_override (id, title, artist, album, genre, year, cover_art_url, cover_art) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", override.ID, override.Title, override.Artist, override.Album, override.Genre, override.Year, override.CoverArtURL, override.CoverArt)
+	c.Semaphore.Release()
+	return err
+}
+
+func (c *Client) GetOverride(ctx context.Context, id string) (Override, error) {
+	c.Semaphore.Acquire()
+	row := c.SQLClient.QueryRow("SELECT id, title, artist, album, genre, year, cover_art_url, cover_art FROM meta_override WHERE id = ?", id)
+	var override Override
+	err := row.Scan(&override.ID, &override.Title, &override.Artist, &override.Album, &override.Genre, &override.Year, &override.CoverArtURL, &override.CoverArt)
+	c.Semaphore.Release()
+	return override, err
+}
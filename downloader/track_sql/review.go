@@ -0,0 +1,49 @@
+package track_sql
+
+import (
+	"context"
+
+	"github.com/gcottom/retry"
+)
+
+func (c *Client) InsertReview(ctx context.Context, review Review) error {
+	c.Semaphore.Acquire()
+	_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "INSERT OR REPLACE INTO review (id, title, artist, candidates, created_at) VALUES (?, ?, ?, ?, ?)", review.ID, review.Title, review.Artist, review.Candidates, review.CreatedAt)
+	c.Semaphore.Release()
+	return err
+}
+
+func (c *Client) GetReview(ctx context.Context, id string) (Review, error) {
+	c.Semaphore.Acquire()
+	row := c.SQLClient.QueryRow("SELECT id, title, artist, candidates, created_at FROM review WHERE id = ?", id)
+	var review Review
+	err := row.Scan(&review.ID, &review.Title, &review.Artist, &review.Candidates, &review.CreatedAt)
+	c.Semaphore.Release()
+	return review, err
+}
+
+func (c *Client) ListReviews(ctx context.Context) ([]Review, error) {
+	c.Semaphore.Acquire()
+	defer c.Semaphore.Release()
+	rows, err := c.SQLClient.Query("SELECT id, title, artist, candidates, created_at FROM review ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reviews := make([]Review, 0)
+	for rows.Next() {
+		var review Review
+		if err := rows.Scan(&review.ID, &review.Title, &review.Artist, &review.Candidates, &review.CreatedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+func (c *Client) DeleteReview(ctx context.Context, id string) error {
+	c.Semaphore.Acquire()
+	_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "DELETE FROM review WHERE id = ?", id)
+	c.Semaphore.Release()
+	return err
+}
@@ -0,0 +1,32 @@
+package track_sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/gcottom/retry"
+)
+
+// GetCachedSearch returns the cached value for key. sql.ErrNoRows is returned
+// when there is no entry or it has expired.
+func (c *Client) GetCachedSearch(ctx context.Context, key string) (string, error) {
+	c.Semaphore.Acquire()
+	row := c.SQLClient.QueryRow("SELECT value FROM search_cache WHERE key = ? AND expires_at > ?", key, time.Now().Unix())
+	var value string
+	err := row.Scan(&value)
+	c.Semaphore.Release()
+	return value, err
+}
+
+// PutCachedSearch stores value under key until ttl has passed, replacing any
+// previous entry. Expired entries are removed at the same time.
+func (c *Client) PutCachedSearch(ctx context.Context, key string, value string, ttl time.Duration) error {
+	c.Semaphore.Acquire()
+	defer c.Semaphore.Release()
+	now := time.Now()
+	if _, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "DELETE FROM search_cache WHERE expires_at <= ?", now.Unix()); err != nil {
+		return err
+	}
+	_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "INSERT OR REPLACE INTO search_cache (key, value, expires_at) VALUES (?, ?, ?)", key, value, now.Add(ttl).Unix())
+	return err
+}
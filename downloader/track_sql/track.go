@@ -8,12 +8,24 @@ import (
 	"github.com/gcottom/retry"
 )
 
+const trackColumns = "id, title, author, artist, album, done, genre, error, error_message, match_score, description, duration, source_id, path, path_template, content_hash, fingerprint, duplicate_of, cuts, bitrate"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTrack(row rowScanner) (Track, error) {
+	var track Track
+	err := row.Scan(&track.ID, &track.Title, &track.Author, &track.Artist, &track.Album, &track.Done, &track.Genre, &track.Error, &track.ErrorMessage, &track.MatchScore, &track.Description, &track.Duration, &track.SourceID, &track.Path, &track.PathTemplate, &track.ContentHash, &track.Fingerprint, &track.DuplicateOf, &track.Cuts, &track.Bitrate)
+	return track, err
+}
+
 func (c *Client) InsertTrack(ctx context.Context, track Track) error {
 	_, err := c.GetTrack(ctx, track.ID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.Semaphore.Acquire()
-			_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "INSERT INTO track (id, title, author, artist, album, done, genre, error, error_message) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", track.ID, track.Title, track.Author, track.Artist, track.Album, track.Done, track.Genre, track.Error, track.ErrorMessage)
+			_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "INSERT INTO track (id, title, author, artist, album, done, genre, error, error_message, match_score, description, duration, source_id, path, path_template, content_hash, fingerprint, duplicate_of, cuts, bitrate) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", track.ID, track.Title, track.Author, track.Artist, track.Album, track.Done, track.Genre, track.Error, track.ErrorMessage, track.MatchScore, track.Description, track.Duration, track.SourceID, track.Path, track.PathTemplate, track.ContentHash, track.Fingerprint, track.DuplicateOf, track.Cuts, track.Bitrate)
 			c.Semaphore.Release()
 			return err
 		} else {
@@ -25,16 +37,86 @@ func (c *Client) InsertTrack(ctx context.Context, track Track) error {
 
 func (c *Client) GetTrack(ctx context.Context, id string) (Track, error) {
 	c.Semaphore.Acquire()
-	row := c.SQLClient.QueryRow("SELECT id, title, author, artist, album, done, genre, error, error_message FROM track WHERE id = ?", id)
-	var track Track
-	err := row.Scan(&track.ID, &track.Title, &track.Author, &track.Artist, &track.Album, &track.Done, &track.Genre, &track.Error, &track.ErrorMessage)
+	row := c.SQLClient.QueryRow("SELECT "+trackColumns+" FROM track WHERE id = ?", id)
+	track, err := scanTrack(row)
+	c.Semaphore.Release()
+	return track, err
+}
+
+// ListTracks returns every track that has been downloaded and saved.
+func (c *Client) ListTracks(ctx context.Context) ([]Track, error) {
+	c.Semaphore.Acquire()
+	defer c.Semaphore.Release()
+	rows, err := c.SQLClient.Query("SELECT " + trackColumns + " FROM track WHERE done = 1 ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tracks := make([]Track, 0)
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+// GetTrackByPath returns the track whose file is saved at path.
+func (c *Client) GetTrackByPath(ctx context.Context, path string) (Track, error) {
+	c.Semaphore.Acquire()
+	row := c.SQLClient.QueryRow("SELECT "+trackColumns+" FROM track WHERE path = ?", path)
+	track, err := scanTrack(row)
 	c.Semaphore.Release()
 	return track, err
 }
 
+// ListFingerprintedTracks returns the saved tracks other than id that have a
+// fingerprint and a length within maxDifference milliseconds of duration.
+func (c *Client) ListFingerprintedTracks(ctx context.Context, id string, duration int, maxDifference int) ([]Track, error) {
+	c.Semaphore.Acquire()
+	defer c.Semaphore.Release()
+	rows, err := c.SQLClient.Query("SELECT "+trackColumns+" FROM track WHERE done = 1 AND fingerprint != '' AND id != ? AND duration BETWEEN ? AND ? ORDER BY id", id, duration-maxDifference, duration+maxDifference)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tracks := make([]Track, 0)
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+// ListDuplicates returns every track found to be the same recording as
+// another track.
+func (c *Client) ListDuplicates(ctx context.Context) ([]Track, error) {
+	c.Semaphore.Acquire()
+	defer c.Semaphore.Release()
+	rows, err := c.SQLClient.Query("SELECT " + trackColumns + " FROM track WHERE duplicate_of != '' ORDER BY duplicate_of, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tracks := make([]Track, 0)
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
 func (c *Client) UpdateTrack(ctx context.Context, track Track) error {
 	c.Semaphore.Acquire()
-	_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "UPDATE track SET title = ?, author = ?, artist = ?, album = ?, done = ?, genre = ?, error = ?, error_message = ? WHERE id = ?", track.Title, track.Author, track.Artist, track.Album, track.Done, track.Genre, track.Error, track.ErrorMessage, track.ID)
+	_, err := retry.Retry(retry.NewAlgFibonacciDefault(), 5, c.SQLClient.Exec, "UPDATE track SET title = ?, author = ?, artist = ?, album = ?, done = ?, genre = ?, error = ?, error_message = ?, match_score = ?, description = ?, duration = ?, source_id = ?, path = ?, path_template = ?, content_hash = ?, fingerprint = ?, duplicate_of = ?, cuts = ?, bitrate = ? WHERE id = ?", track.Title, track.Author, track.Artist, track.Album, track.Done, track.Genre, track.Error, track.ErrorMessage, track.MatchScore, track.Description, track.Duration, track.SourceID, track.Path, track.PathTemplate, track.ContentHash, track.Fingerprint, track.DuplicateOf, track.Cuts, track.Bitrate, track.ID)
 	c.Semaphore.Release()
 	return err
 }
@@ -23,4 +23,47 @@ type Track struct {
 	Genre        string
 	Error        int
 	ErrorMessage string
+	MatchScore   float64
+	Description  string
+	Duration     int // length in milliseconds
+	SourceID     string
+	Path         string // where the tagged file was saved
+	PathTemplate string // template the path was rendered from, empty for the configured one
+	ContentHash  string // hex SHA-256 of the saved file
+	Fingerprint  string // packed Chromaprint fingerprint of the audio
+	DuplicateOf  string // ID of the track this is the same recording as
+	Cuts         string // packed segments cut out of the audio
+	Bitrate      int    // bits per second of the audio stream downloaded from YouTube
+}
+
+// SourceVideoID returns the ID of the video the audio was downloaded from,
+// which differs from ID when an art track was preferred over a music video.
+func (t Track) SourceVideoID() string {
+	if t.SourceID != "" {
+		return t.SourceID
+	}
+	return t.ID
+}
+
+// Review is a track whose best metadata match scored too low to be written
+// without a person choosing one.
+type Review struct {
+	ID         string
+	Title      string // YouTube title
+	Artist     string // YouTube channel
+	Candidates string // JSON encoded candidates, best first
+	CreatedAt  int64
+}
+
+// Override is metadata set by hand for a track. Its fields replace the
+// matched metadata whenever the track is tagged.
+type Override struct {
+	ID          string
+	Title       string
+	Artist      string
+	Album       string
+	Genre       string
+	Year        int
+	CoverArtURL string
+	CoverArt    []byte // processed JPEG of an uploaded cover
 }
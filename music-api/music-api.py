@@ -38,7 +38,35 @@ class SimpleHTTPRequestHandler(http.server.SimpleHTTPRequestHandler):
                 'title': data['videoDetails']['title'],
                 'author': data['videoDetails']['author'],
                 'image': data['videoDetails']['thumbnail']['thumbnails'][-1]['url'],
-                'type': vtype
+                'type': vtype,
+                'duration': int(data['videoDetails'].get('lengthSeconds', 0))
+            }
+            self.send_response(200)
+            self.end_headers()
+            self.wfile.write(json.dumps(response).encode('utf-8'))
+        elif path == '/atv':
+            id = query_params['id'][0]
+            data = ytmusic.get_song(id)
+            details = data['videoDetails']
+            vtype = details['musicVideoType'].lower()
+            candidates = []
+            if "atv" not in vtype:
+                results = ytmusic.search(f"{details['title']} {details['author']}", filter='songs', limit=5)
+                for r in results:
+                    if not r.get('videoId'):
+                        continue
+                    candidates.append({
+                        'id': r['videoId'],
+                        'title': r['title'],
+                        'author': ', '.join(a['name'] for a in r.get('artists', [])),
+                        'duration': r.get('duration_seconds', 0)
+                    })
+            response = {
+                'title': details['title'],
+                'author': details['author'],
+                'type': "atv" if "atv" in vtype else "omv",
+                'duration': int(details.get('lengthSeconds', 0)),
+                'candidates': candidates
             }
             self.send_response(200)
             self.end_headers()